@@ -1,9 +1,9 @@
 module github.com/nc30/golang_examples
 
-go 1.13
+go 1.21
 
 require (
 	github.com/go-chi/chi v4.1.2+incompatible // indirect
-	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c // indirect
+	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c
 	golang.org/x/text v0.3.7 // indirect
 )
@@ -0,0 +1,126 @@
+/*
+goroutine/のサンプルで出てきたパターンを使い回せるようにしたパッケージ
+
+docs:
+  - https://pkg.go.dev/sync#WaitGroup
+  - https://pkg.go.dev/context#WithCancel
+*/
+package parallel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Errors は複数のgoroutineから返ってきたエラーをまとめたもの
+// errors.Is/Asは中のすべてのエラーに対して働く
+type Errors []error
+
+func (e Errors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d errors occurred: %s", len(e), strings.Join(msgs, "; "))
+}
+
+func (e Errors) Unwrap() []error { return e }
+
+// Group はエラーを返す関数を並列に動かして、すべてのエラーを集める
+// withWaitGroup.goのsync.WaitGroupではエラーを扱いづらかったのでその代わり
+//
+//	g := parallel.NewGroup(ctx)
+//	for _, url := range list {
+//		url := url
+//		g.Go(func(ctx context.Context) error { return fetch(ctx, url) })
+//	}
+//	if err := g.Wait(); err != nil {
+//		log.Println(err) // 2 errors occurred: ...; ...
+//	}
+type Group struct {
+	wg       sync.WaitGroup
+	ctx      context.Context
+	cancel   context.CancelFunc
+	failFast bool
+
+	mu     sync.Mutex
+	errs   Errors
+	failed bool
+}
+
+// NewGroup はすべての関数を最後まで動かすGroupを作る
+// 関数に渡すcontextはctxから作られ、Waitが戻るとキャンセルされる
+func NewGroup(ctx context.Context) *Group {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Group{ctx: ctx, cancel: cancel}
+}
+
+// NewFailFastGroup は1つでもエラーを返したら残りの関数のcontextをキャンセルするGroupを作る
+// withContext.goと同じく、キャンセルに気づけるよう関数の中でctx.Done()を見ること
+// キャンセルされた関数が返したcontext.Canceledは、元のエラーと紛らわしいのでWaitの結果に含めない
+func NewFailFastGroup(ctx context.Context) *Group {
+	g := NewGroup(ctx)
+	g.failFast = true
+	return g
+}
+
+// Context は関数に渡されるcontextを返す
+func (g *Group) Context() context.Context {
+	return g.ctx
+}
+
+// Go はfを新しいgoroutineで動かす
+// fの中のpanicは拾ってエラーとして扱う
+func (g *Group) Go(f func(ctx context.Context) error) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+
+		var err error
+		func() {
+			defer func() {
+				if p := recover(); p != nil {
+					err = fmt.Errorf("parallel: panic: %v", p)
+				}
+			}()
+			err = f(g.ctx)
+		}()
+
+		if err != nil {
+			g.add(err)
+		}
+	}()
+}
+
+func (g *Group) add(err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.failFast {
+		if g.failed && errors.Is(err, context.Canceled) {
+			return
+		}
+		g.failed = true
+		g.cancel()
+	}
+	g.errs = append(g.errs, err)
+}
+
+// Wait はすべての関数が終わるまで待ち、エラーがあればErrorsとして返す
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	g.cancel()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.errs) == 0 {
+		return nil
+	}
+	return append(Errors(nil), g.errs...)
+}
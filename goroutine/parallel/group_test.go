@@ -0,0 +1,129 @@
+package parallel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	errA = errors.New("a failed")
+	errB = errors.New("b failed")
+)
+
+func TestGroupCollectsAllErrors(t *testing.T) {
+	g := NewGroup(context.Background())
+	finished := make(chan struct{})
+	g.Go(func(ctx context.Context) error { return errA })
+	g.Go(func(ctx context.Context) error { return fmt.Errorf("fetch: %w", errB) })
+	g.Go(func(ctx context.Context) error { return nil })
+	g.Go(func(ctx context.Context) error {
+		// 通常のGroupでは他がエラーでもキャンセルされず最後まで動く
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(20 * time.Millisecond):
+			close(finished)
+			return nil
+		}
+	})
+
+	err := g.Wait()
+	var errs Errors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("Wait() = %v, want 2 errors", err)
+	}
+	select {
+	case <-finished:
+	default:
+		t.Error("sibling was cancelled")
+	}
+	if !strings.HasPrefix(err.Error(), "2 errors occurred: ") {
+		t.Errorf("Error() = %q", err.Error())
+	}
+	// Waitが戻ったらcontextはキャンセルされる
+	if g.Context().Err() == nil {
+		t.Error("context is not cancelled after Wait")
+	}
+}
+
+func TestGroupNoError(t *testing.T) {
+	g := NewGroup(context.Background())
+	for i := 0; i < 5; i++ {
+		g.Go(func(ctx context.Context) error { return nil })
+	}
+	if err := g.Wait(); err != nil {
+		t.Errorf("Wait() = %v, want nil", err)
+	}
+}
+
+func TestFailFastGroup(t *testing.T) {
+	g := NewFailFastGroup(context.Background())
+	for i := 0; i < 3; i++ {
+		g.Go(func(ctx context.Context) error {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(5 * time.Second):
+				return errors.New("not cancelled")
+			}
+		})
+	}
+	g.Go(func(ctx context.Context) error { return errA })
+
+	start := time.Now()
+	err := g.Wait()
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Wait took %v, siblings were not cancelled", d)
+	}
+	// キャンセルされた側のcontext.Canceledは含まれない
+	var errs Errors
+	if !errors.As(err, &errs) || len(errs) != 1 || !errors.Is(err, errA) {
+		t.Fatalf("Wait() = %v, want only errA", err)
+	}
+	if errors.Is(err, context.Canceled) {
+		t.Error("context.Canceled should be filtered")
+	}
+	if err.Error() != errA.Error() {
+		t.Errorf("Error() = %q, want %q", err.Error(), errA.Error())
+	}
+}
+
+// 最初のエラーがcontext.Canceledの場合はそれ自体は残す
+func TestFailFastGroupParentCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	g := NewFailFastGroup(ctx)
+	g.Go(func(ctx context.Context) error { return ctx.Err() })
+	if err := g.Wait(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Wait() = %v, want context.Canceled", err)
+	}
+}
+
+func TestGroupPanic(t *testing.T) {
+	g := NewGroup(context.Background())
+	g.Go(func(ctx context.Context) error { panic("boom") })
+	g.Go(func(ctx context.Context) error { return nil })
+
+	err := g.Wait()
+	if err == nil || !strings.Contains(err.Error(), "panic: boom") {
+		t.Errorf("Wait() = %v, want panic error", err)
+	}
+}
+
+func TestErrorsUnwrap(t *testing.T) {
+	err := fmt.Errorf("job: %w", Errors{errA, fmt.Errorf("wrapped: %w", errB)})
+	if !errors.Is(err, errA) || !errors.Is(err, errB) {
+		t.Errorf("errors.Is failed for %v", err)
+	}
+	if errors.Is(err, context.Canceled) {
+		t.Errorf("errors.Is(%v, context.Canceled) = true", err)
+	}
+	var errs Errors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Errorf("errors.As = %v", errs)
+	}
+}
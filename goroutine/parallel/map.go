@@ -0,0 +1,62 @@
+package parallel
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// ParallelMap はitemsのそれぞれにfnを適用し、結果とエラーをitemsと同じ順番で返す
+// 同時に動かすのはconcurrency個まで (semaphore.goと同じくsemaphoreで制御する)
+//
+//	bodies, errs := parallel.ParallelMap(ctx, urls, 3, fetch)
+//	for i, url := range urls {
+//		if errs[i] != nil { ... }
+//		log.Println(url, len(bodies[i]))
+//	}
+//
+// ctxが終わった時点でまだ始まっていない要素はfnを呼ばず、エラーにctx.Err()が入る
+// 動いている要素を止めたい場合はfnの中でctx.Done()を見ること
+func ParallelMap[T, R any](ctx context.Context, items []T, concurrency int, fn func(ctx context.Context, item T) (R, error)) ([]R, []error) {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	results := make([]R, len(items))
+	errs := make([]error, len(items))
+
+	sem := semaphore.NewWeighted(int64(concurrency))
+	var wg sync.WaitGroup
+
+	for i, item := range items {
+		if err := sem.Acquire(ctx, 1); err != nil {
+			for j := i; j < len(items); j++ {
+				errs[j] = err
+			}
+			break
+		}
+
+		wg.Add(1)
+		go func(i int, item T) {
+			defer wg.Done()
+			defer sem.Release(1)
+			defer func() {
+				if p := recover(); p != nil {
+					errs[i] = fmt.Errorf("parallel: panic: %v", p)
+				}
+			}()
+
+			// 空きを待っている間にキャンセルされた場合もfnは呼ばない
+			if err := ctx.Err(); err != nil {
+				errs[i] = err
+				return
+			}
+			// それぞれ別のインデックスにしか書き込まないのでロックはいらない
+			results[i], errs[i] = fn(ctx, item)
+		}(i, item)
+	}
+
+	wg.Wait()
+	return results, errs
+}
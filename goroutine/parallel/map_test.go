@@ -0,0 +1,111 @@
+package parallel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParallelMapOrder(t *testing.T) {
+	items := []int{5, 4, 3, 2, 1, 0}
+	results, errs := ParallelMap(context.Background(), items, 3, func(ctx context.Context, v int) (string, error) {
+		// 後ろの要素ほど早く終わる
+		time.Sleep(time.Duration(v) * 5 * time.Millisecond)
+		if v%2 == 1 {
+			return "", fmt.Errorf("odd %d", v)
+		}
+		return fmt.Sprint(v), nil
+	})
+
+	for i, v := range items {
+		if v%2 == 1 {
+			if errs[i] == nil || errs[i].Error() != fmt.Sprintf("odd %d", v) {
+				t.Errorf("errs[%d] = %v", i, errs[i])
+			}
+			continue
+		}
+		if errs[i] != nil || results[i] != fmt.Sprint(v) {
+			t.Errorf("[%d] = %q, %v, want %q", i, results[i], errs[i], fmt.Sprint(v))
+		}
+	}
+}
+
+func TestParallelMapConcurrency(t *testing.T) {
+	const limit = 3
+	var running, max int64
+	items := make([]int, 30)
+	_, errs := ParallelMap(context.Background(), items, limit, func(ctx context.Context, v int) (int, error) {
+		n := atomic.AddInt64(&running, 1)
+		defer atomic.AddInt64(&running, -1)
+		for {
+			m := atomic.LoadInt64(&max)
+			if n <= m || atomic.CompareAndSwapInt64(&max, m, n) {
+				break
+			}
+		}
+		time.Sleep(2 * time.Millisecond)
+		return v, nil
+	})
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("errs[%d] = %v", i, err)
+		}
+	}
+	if max > limit {
+		t.Errorf("max concurrency = %d, want <= %d", max, limit)
+	}
+	if max < 2 {
+		t.Errorf("max concurrency = %d, items did not run in parallel", max)
+	}
+}
+
+func TestParallelMapCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	called := map[int]bool{}
+	items := []int{0, 1, 2, 3, 4, 5, 6, 7}
+	results, errs := ParallelMap(ctx, items, 1, func(ctx context.Context, v int) (int, error) {
+		mu.Lock()
+		called[v] = true
+		mu.Unlock()
+		if v == 2 {
+			cancel()
+		}
+		return v * 10, nil
+	})
+
+	for i, v := range items {
+		if v <= 2 {
+			if !called[v] || errs[i] != nil || results[i] != v*10 {
+				t.Errorf("[%d] = %d, %v, called = %v", i, results[i], errs[i], called[v])
+			}
+			continue
+		}
+		// キャンセル後の要素はfnが呼ばれず、エラーが入る
+		if called[v] {
+			t.Errorf("fn was called for %d after cancel", v)
+		}
+		if !errors.Is(errs[i], context.Canceled) {
+			t.Errorf("errs[%d] = %v, want context.Canceled", i, errs[i])
+		}
+	}
+}
+
+func TestParallelMapPanic(t *testing.T) {
+	_, errs := ParallelMap(context.Background(), []int{0, 1}, 2, func(ctx context.Context, v int) (int, error) {
+		if v == 1 {
+			panic("boom")
+		}
+		return v, nil
+	})
+	if errs[0] != nil || errs[1] == nil {
+		t.Errorf("errs = %v", errs)
+	}
+}
@@ -0,0 +1,186 @@
+package parallel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	// ErrQueueFull はNonBlockingのPoolでキューが一杯のときにSubmitが返す
+	ErrQueueFull = errors.New("parallel: queue is full")
+	// ErrStopped は止めたPoolにSubmitしたときと、Stop(false)で捨てたジョブの結果
+	ErrStopped = errors.New("parallel: pool is stopped")
+)
+
+// PoolConfig はPoolのワーカー数とキューの長さ
+type PoolConfig struct {
+	// Workers は同時に動かすジョブの数。0以下なら1
+	Workers int
+	// QueueSize は実行待ちのジョブを溜めておける数
+	QueueSize int
+	// NonBlocking がtrueならキューが一杯のときSubmitはErrQueueFullを返す
+	// falseなら空くまで待つ
+	NonBlocking bool
+}
+
+// Result は1つのジョブの結果。IDはSubmitが返したもの
+type Result[T any] struct {
+	ID    uint64
+	Value T
+	Err   error
+}
+
+type job[T any] struct {
+	id uint64
+	fn func(ctx context.Context) (T, error)
+}
+
+// Pool は決まった数のワーカーでジョブを処理し続ける
+// semaphore.goはジョブごとにgoroutineを作っていたが、こちらはワーカーを使い回す
+//
+//	pool := parallel.NewPool[string](parallel.PoolConfig{Workers: 2, QueueSize: 10})
+//	go func() {
+//		for r := range pool.Results() {
+//			log.Println(r.ID, r.Value, r.Err)
+//		}
+//	}()
+//	pool.Submit(ctx, func(ctx context.Context) (string, error) { ... })
+//	pool.Stop(true)
+//
+// 結果はResults()に送られるので、閉じられるまで必ず読み続けること
+// 読まないとワーカーが止まり、Stopも戻らない
+type Pool[T any] struct {
+	conf    PoolConfig
+	jobs    chan job[T]
+	results chan Result[T]
+	nextID  uint64
+
+	// ワーカーで動いているジョブに渡すcontext。Stop(false)でキャンセルする
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	// stoppedとjobsへの送信の競合を防ぐ
+	mu        sync.RWMutex
+	stopped   bool
+	abandoned int32
+
+	// キューが空くのを待っているSubmitを、Stopが始まった時点で抜けさせる
+	// Submitが読み込みロックを持ったまま待っているとStopが書き込みロックを取れないため
+	stopping chan struct{}
+	stopOnce sync.Once
+	// 最初のStopがワーカーの終了を待ってresultsを閉じたら閉じる
+	done chan struct{}
+}
+
+// NewPool はワーカーを起動したPoolを作る
+func NewPool[T any](conf PoolConfig) *Pool[T] {
+	if conf.Workers <= 0 {
+		conf.Workers = 1
+	}
+	if conf.QueueSize < 0 {
+		conf.QueueSize = 0
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Pool[T]{
+		conf:     conf,
+		jobs:     make(chan job[T], conf.QueueSize),
+		results:  make(chan Result[T], conf.Workers),
+		ctx:      ctx,
+		cancel:   cancel,
+		stopping: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	p.wg.Add(conf.Workers)
+	for i := 0; i < conf.Workers; i++ {
+		go p.worker()
+	}
+	return p
+}
+
+func (p *Pool[T]) worker() {
+	defer p.wg.Done()
+	for j := range p.jobs {
+		if atomic.LoadInt32(&p.abandoned) == 1 {
+			p.results <- Result[T]{ID: j.id, Err: ErrStopped}
+			continue
+		}
+		p.results <- p.run(j)
+	}
+}
+
+// ジョブのpanicでワーカーが減らないようにエラーにする
+func (p *Pool[T]) run(j job[T]) (r Result[T]) {
+	r.ID = j.id
+	defer func() {
+		if e := recover(); e != nil {
+			r.Err = fmt.Errorf("parallel: panic: %v", e)
+		}
+	}()
+	r.Value, r.Err = j.fn(p.ctx)
+	return r
+}
+
+// Submit はfnをキューに積み、結果の照合に使うIDを返す
+// キューが一杯の場合、NonBlockingならErrQueueFullを返し、そうでなければ空くかctxが終わるまで待つ
+func (p *Pool[T]) Submit(ctx context.Context, fn func(ctx context.Context) (T, error)) (uint64, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.stopped {
+		return 0, ErrStopped
+	}
+
+	j := job[T]{id: atomic.AddUint64(&p.nextID, 1), fn: fn}
+	if p.conf.NonBlocking {
+		select {
+		case p.jobs <- j:
+			return j.id, nil
+		default:
+			return 0, ErrQueueFull
+		}
+	}
+	select {
+	case p.jobs <- j:
+		return j.id, nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	case <-p.stopping:
+		return 0, ErrStopped
+	}
+}
+
+// Results は結果が送られるチャンネルを返す。Stopが終わると閉じられる
+func (p *Pool[T]) Results() <-chan Result[T] {
+	return p.results
+}
+
+// Stop は新しいジョブの受付をやめ、ワーカーが終わるまで待つ
+// drainがtrueならキューに残っているジョブもすべて処理する
+// falseなら動いているジョブのcontextをキャンセルし、残っているジョブはErrStoppedの結果にする
+// 複数のgoroutineから呼んだ場合も、どれもResults()が閉じられるまで戻らない
+func (p *Pool[T]) Stop(drain bool) {
+	// ロックを取る前にキャンセルして、動いているジョブと待っているSubmitを先に終わらせる
+	if !drain {
+		atomic.StoreInt32(&p.abandoned, 1)
+		p.cancel()
+	}
+	p.stopOnce.Do(func() { close(p.stopping) })
+
+	p.mu.Lock()
+	if p.stopped {
+		p.mu.Unlock()
+		<-p.done
+		return
+	}
+	p.stopped = true
+	close(p.jobs)
+	p.mu.Unlock()
+
+	p.wg.Wait()
+	p.cancel()
+	close(p.results)
+	close(p.done)
+}
@@ -0,0 +1,143 @@
+package parallel
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPoolResults(t *testing.T) {
+	pool := NewPool[int](PoolConfig{Workers: 3, QueueSize: 10})
+	want := map[uint64]int{}
+	go func() {
+		for i := 0; i < 20; i++ {
+			i := i
+			id, err := pool.Submit(context.Background(), func(ctx context.Context) (int, error) { return i * i, nil })
+			if err != nil {
+				t.Error(err)
+			}
+			want[id] = i * i
+		}
+		pool.Stop(true)
+	}()
+
+	got := map[uint64]int{}
+	for r := range pool.Results() {
+		if r.Err != nil {
+			t.Errorf("job %d: %v", r.ID, r.Err)
+		}
+		got[r.ID] = r.Value
+	}
+	if len(got) != 20 {
+		t.Fatalf("got %d results, want 20", len(got))
+	}
+	for id, v := range want {
+		if got[id] != v {
+			t.Errorf("job %d = %d, want %d", id, got[id], v)
+		}
+	}
+}
+
+// ジョブがctxを待っていて、キューが一杯でSubmitが待っている状態でもStop(false)は戻る
+func TestPoolStopWithBlockedSubmit(t *testing.T) {
+	pool := NewPool[int](PoolConfig{Workers: 1, QueueSize: 1})
+	go func() {
+		for range pool.Results() {
+		}
+	}()
+
+	wait := func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}
+	pool.Submit(context.Background(), wait) // 動いているジョブ
+	pool.Submit(context.Background(), wait) // キューに入るジョブ
+
+	submitted := make(chan error)
+	go func() {
+		_, err := pool.Submit(context.Background(), wait)
+		submitted <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	stopped := make(chan struct{})
+	go func() {
+		pool.Stop(false)
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop(false) deadlocked")
+	}
+	if err := <-submitted; !errors.Is(err, ErrStopped) {
+		t.Errorf("blocked Submit = %v, want ErrStopped", err)
+	}
+	if _, err := pool.Submit(context.Background(), wait); !errors.Is(err, ErrStopped) {
+		t.Errorf("Submit after Stop = %v, want ErrStopped", err)
+	}
+}
+
+// 2回目のStopも、最初のStopと同じくワーカーが終わってResults()が閉じられるまで戻らない
+func TestPoolConcurrentStop(t *testing.T) {
+	pool := NewPool[int](PoolConfig{Workers: 1})
+	release := make(chan struct{})
+	pool.Submit(context.Background(), func(ctx context.Context) (int, error) {
+		<-release
+		return 1, nil
+	})
+	results := make(chan int)
+	go func() {
+		n := 0
+		for range pool.Results() {
+			n++
+		}
+		results <- n
+	}()
+
+	first := make(chan struct{})
+	go func() {
+		pool.Stop(true)
+		close(first)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		pool.mu.RLock()
+		stopped := pool.stopped
+		pool.mu.RUnlock()
+		if stopped {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("first Stop did not start")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	second := make(chan struct{})
+	go func() {
+		pool.Stop(true)
+		close(second)
+	}()
+	select {
+	case <-second:
+		t.Fatal("second Stop returned while a job was running")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	for _, ch := range []chan struct{}{first, second} {
+		select {
+		case <-ch:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Stop did not return")
+		}
+	}
+	if n := <-results; n != 1 {
+		t.Errorf("got %d results, want 1", n)
+	}
+	// 止めた後のStopもすぐ戻る
+	pool.Stop(false)
+}
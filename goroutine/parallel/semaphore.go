@@ -0,0 +1,172 @@
+package parallel
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+type waiter struct {
+	n     int64
+	ready chan struct{}
+}
+
+// Semaphore は上限を実行中に変えられるsemaphore
+// semaphore.goで使ったgolang.org/x/sync/semaphoreと同じく、
+// Acquireした順に空きを割り当てるので大きなnのAcquireが後回しにされ続けることはない
+//
+//	sem := parallel.NewSemaphore(2)
+//	mux.Handle("/admin/workers", sem) // curl -X PUT -d '{"size":8}' で同時処理数を増やせる
+type Semaphore struct {
+	mu      sync.Mutex
+	size    int64
+	cur     int64
+	waiters list.List
+}
+
+// NewSemaphore は上限nのSemaphoreを作る
+func NewSemaphore(n int64) *Semaphore {
+	return &Semaphore{size: n}
+}
+
+// Acquire はnだけ確保する。空きができるかctxが終わるまで待つ
+// ctxが終わった場合はctx.Err()を返し、何も確保しない
+func (s *Semaphore) Acquire(ctx context.Context, n int64) error {
+	s.mu.Lock()
+	if s.cur+n <= s.size && s.waiters.Len() == 0 {
+		s.cur += n
+		s.mu.Unlock()
+		return nil
+	}
+
+	ready := make(chan struct{})
+	elem := s.waiters.PushBack(waiter{n: n, ready: ready})
+	s.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		select {
+		case <-ready:
+			// キャンセルと同時に確保できていた場合は確保できた方を優先する
+			s.mu.Unlock()
+			return nil
+		default:
+		}
+		isFront := s.waiters.Front() == elem
+		s.waiters.Remove(elem)
+		// 先頭が抜けたことで後ろのものが入れるようになるかもしれない
+		if isFront {
+			s.notifyWaiters()
+		}
+		s.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// TryAcquire は待たずにnだけ確保する。確保できなければfalseを返す
+func (s *Semaphore) TryAcquire(n int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cur+n <= s.size && s.waiters.Len() == 0 {
+		s.cur += n
+		return true
+	}
+	return false
+}
+
+// Release はnだけ解放する。確保した以上に解放するとpanicする
+func (s *Semaphore) Release(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cur -= n
+	if s.cur < 0 {
+		panic("parallel: semaphore released more than held")
+	}
+	s.notifyWaiters()
+}
+
+// Resize は上限をnに変える
+// 増やした場合は待っているAcquireがすぐに動き出す
+// 減らした場合は確保済みのものはそのままで、解放されて実行中の合計がn未満になるまで新しいAcquireは待たされる
+func (s *Semaphore) Resize(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.size = n
+	s.notifyWaiters()
+}
+
+// Size は現在の上限を返す
+func (s *Semaphore) Size() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size
+}
+
+// Current は確保されている合計を返す。上限を減らした直後は上限より大きいことがある
+func (s *Semaphore) Current() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cur
+}
+
+// 先頭から順に入れるだけ入れる。先頭が入れない場合は後ろが小さくても入れない
+func (s *Semaphore) notifyWaiters() {
+	for {
+		next := s.waiters.Front()
+		if next == nil {
+			return
+		}
+		w := next.Value.(waiter)
+		if s.cur+w.n > s.size {
+			return
+		}
+		s.cur += w.n
+		s.waiters.Remove(next)
+		close(w.ready)
+	}
+}
+
+// SemaphoreStatus はServeHTTPが返す状態
+type SemaphoreStatus struct {
+	Size    int64 `json:"size"`
+	Current int64 `json:"current"`
+	Waiting int   `json:"waiting"`
+}
+
+// Status は現在の状態を返す
+func (s *Semaphore) Status() SemaphoreStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return SemaphoreStatus{Size: s.size, Current: s.cur, Waiting: s.waiters.Len()}
+}
+
+// ServeHTTP はGETで状態を返し、PUT {"size": 8} で上限を変える
+func (s *Semaphore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodPut:
+		var body struct {
+			Size *int64 `json:"size"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if body.Size == nil || *body.Size < 0 {
+			http.Error(w, "parallel: size must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		s.Resize(*body.Size)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(s.Status())
+}
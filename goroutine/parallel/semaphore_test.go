@@ -0,0 +1,168 @@
+package parallel
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 別のgoroutineでAcquireし、戻ったら結果を送る
+func acquireAsync(s *Semaphore, ctx context.Context, n int64) <-chan error {
+	done := make(chan error, 1)
+	go func() { done <- s.Acquire(ctx, n) }()
+	return done
+}
+
+// 待っているAcquireがn個になるまで待つ
+func waitWaiting(t *testing.T, s *Semaphore, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for s.Status().Waiting != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("Waiting = %d, want %d", s.Status().Waiting, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func acquired(t *testing.T, done <-chan error) {
+	t.Helper()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Acquire = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Acquire did not return")
+	}
+}
+
+func blocked(t *testing.T, done <-chan error) {
+	t.Helper()
+	select {
+	case err := <-done:
+		t.Fatalf("Acquire returned %v, want blocked", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestSemaphoreShrink(t *testing.T) {
+	ctx := context.Background()
+	s := NewSemaphore(3)
+	for i := 0; i < 3; i++ {
+		if err := s.Acquire(ctx, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 確保済みの3つはそのまま
+	s.Resize(1)
+	if s.Current() != 3 {
+		t.Errorf("Current() = %d, want 3", s.Current())
+	}
+	if s.TryAcquire(1) {
+		t.Fatal("TryAcquire succeeded over the new size")
+	}
+
+	done := acquireAsync(s, ctx, 1)
+	blocked(t, done)
+	s.Release(1)
+	blocked(t, done)
+	s.Release(1)
+	blocked(t, done)
+	// 実行中が0になって初めて1つ入れる
+	s.Release(1)
+	acquired(t, done)
+	if s.Current() != 1 {
+		t.Errorf("Current() = %d, want 1", s.Current())
+	}
+}
+
+func TestSemaphoreGrowWakesInOrder(t *testing.T) {
+	ctx := context.Background()
+	s := NewSemaphore(1)
+	s.Acquire(ctx, 1)
+
+	first := acquireAsync(s, ctx, 2)
+	waitWaiting(t, s, 1)
+	second := acquireAsync(s, ctx, 1)
+	waitWaiting(t, s, 2)
+
+	// 先頭の2が入れないうちは後ろの1も入れない
+	s.Resize(2)
+	blocked(t, first)
+	blocked(t, second)
+
+	s.Resize(3)
+	acquired(t, first)
+	blocked(t, second)
+
+	s.Resize(4)
+	acquired(t, second)
+	if st := s.Status(); st.Current != 4 || st.Waiting != 0 {
+		t.Errorf("Status() = %+v", st)
+	}
+}
+
+func TestSemaphoreCancelFrontWaiter(t *testing.T) {
+	s := NewSemaphore(2)
+	s.Acquire(context.Background(), 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	front := acquireAsync(s, ctx, 2)
+	waitWaiting(t, s, 1)
+	behind := acquireAsync(s, context.Background(), 1)
+	waitWaiting(t, s, 2)
+	blocked(t, behind)
+
+	// 先頭が諦めたら、空いている1に後ろが入れる
+	cancel()
+	select {
+	case err := <-front:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("front Acquire = %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancelled Acquire did not return")
+	}
+	acquired(t, behind)
+	if st := s.Status(); st.Current != 2 || st.Waiting != 0 {
+		t.Errorf("Status() = %+v", st)
+	}
+}
+
+func TestSemaphoreServeHTTP(t *testing.T) {
+	s := NewSemaphore(2)
+	tests := []struct {
+		method   string
+		body     string
+		wantCode int
+		wantSize int64
+	}{
+		{http.MethodGet, "", http.StatusOK, 2},
+		{http.MethodPut, `{"size":8}`, http.StatusOK, 8},
+		{http.MethodPut, `{"size":0}`, http.StatusOK, 0},
+		{http.MethodPut, `{}`, http.StatusBadRequest, 0},
+		{http.MethodPut, `{"size":-1}`, http.StatusBadRequest, 0},
+		{http.MethodPut, `{"size":"8"}`, http.StatusBadRequest, 0},
+		{http.MethodPut, `not json`, http.StatusBadRequest, 0},
+		{http.MethodPost, `{"size":4}`, http.StatusMethodNotAllowed, 0},
+		{http.MethodPut, `{"size":4}`, http.StatusOK, 4},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/admin/workers", strings.NewReader(tt.body))
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		if rec.Code != tt.wantCode {
+			t.Errorf("%s %s: code = %d, want %d", tt.method, tt.body, rec.Code, tt.wantCode)
+		}
+		if s.Size() != tt.wantSize {
+			t.Errorf("%s %s: Size() = %d, want %d", tt.method, tt.body, s.Size(), tt.wantSize)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+/*
+channelでつないだ処理のパイプライン
+
+各ステージはgoroutineで動き、入力のchannelが閉じられると出力のchannelを閉じる
+途中でやめたい場合はctxをキャンセルする。
+すべてのステージはctx.Done()を見ているので、下流が読むのをやめてもgoroutineが残ることはない
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // 途中でreturnしても上流のgoroutineが終わる
+
+	urls := pipeline.Source(ctx, list...)
+	bodies := pipeline.Map(ctx, urls, 3, fetch)
+	for batch := range pipeline.Batch(ctx, bodies, 10, time.Second) {
+		...
+	}
+
+docs:
+  - https://go.dev/blog/pipelines
+  - https://pkg.go.dev/context
+*/
+package pipeline
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// vをoutに送る。ctxが終わった場合はfalseを返す
+func send[T any](ctx context.Context, out chan<- T, v T) bool {
+	select {
+	case out <- v:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// inから1つ読む。inが閉じられたかctxが終わった場合はfalseを返す
+// rangeで回すと上流がctxを見ていない場合に止まれないのでこちらを使う
+func recv[T any](ctx context.Context, in <-chan T) (T, bool) {
+	select {
+	case v, ok := <-in:
+		return v, ok
+	case <-ctx.Done():
+		var zero T
+		return zero, false
+	}
+}
+
+// Source はitemsを順に送るchannelを返す
+func Source[T any](ctx context.Context, items ...T) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for _, v := range items {
+			if !send(ctx, out, v) {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// workers個のgoroutineでfを動かし、すべて終わったらoutを閉じる
+func spawn[T any](workers int, out chan T, f func()) {
+	if workers <= 0 {
+		workers = 1
+	}
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			f()
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+}
+
+// Map はinの各値にfnを適用した結果を送る
+// workers個のgoroutineで並列に処理するので、workersが2以上の場合は順番が入れ替わる
+// エラーを扱いたい場合は、値とエラーを持つ構造体をRにする
+func Map[T, R any](ctx context.Context, in <-chan T, workers int, fn func(ctx context.Context, v T) R) <-chan R {
+	out := make(chan R)
+	spawn(workers, out, func() {
+		for {
+			v, ok := recv(ctx, in)
+			if !ok || !send(ctx, out, fn(ctx, v)) {
+				return
+			}
+		}
+	})
+	return out
+}
+
+// Filter はfnがtrueを返した値だけを送る。workersの扱いはMapと同じ
+func Filter[T any](ctx context.Context, in <-chan T, workers int, fn func(ctx context.Context, v T) bool) <-chan T {
+	out := make(chan T)
+	spawn(workers, out, func() {
+		for {
+			v, ok := recv(ctx, in)
+			if !ok {
+				return
+			}
+			if fn(ctx, v) && !send(ctx, out, v) {
+				return
+			}
+		}
+	})
+	return out
+}
+
+// Batch はsize個ずつまとめて送る
+// maxWaitが0より大きい場合、最初の値からmaxWait経ってもsize個に満たなければその時点の分を送る
+// inが閉じられたときに残っている分も送る
+func Batch[T any](ctx context.Context, in <-chan T, size int, maxWait time.Duration) <-chan []T {
+	if size <= 0 {
+		size = 1
+	}
+	out := make(chan []T)
+	go func() {
+		defer close(out)
+
+		var batch []T
+		var timeout <-chan time.Time
+		var timer *time.Timer
+		defer func() {
+			if timer != nil {
+				timer.Stop()
+			}
+		}()
+
+		flush := func() bool {
+			if timer != nil {
+				timer.Stop()
+				timer, timeout = nil, nil
+			}
+			if len(batch) == 0 {
+				return true
+			}
+			b := batch
+			batch = nil
+			return send(ctx, out, b)
+		}
+
+		for {
+			select {
+			case v, ok := <-in:
+				if !ok {
+					flush()
+					return
+				}
+				batch = append(batch, v)
+				if len(batch) == 1 && maxWait > 0 {
+					timer = time.NewTimer(maxWait)
+					timeout = timer.C
+				}
+				if len(batch) >= size && !flush() {
+					return
+				}
+			case <-timeout:
+				if !flush() {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// FanOut はinの値をn個のchannelに振り分ける。各値はどれか1つのchannelにだけ送られる
+// 読むのが早いchannelほど多く受け取る
+func FanOut[T any](ctx context.Context, in <-chan T, n int) []<-chan T {
+	if n <= 0 {
+		n = 1
+	}
+	outs := make([]<-chan T, n)
+	for i := range outs {
+		out := make(chan T)
+		outs[i] = out
+		go func() {
+			defer close(out)
+			for {
+				v, ok := recv(ctx, in)
+				if !ok || !send(ctx, out, v) {
+					return
+				}
+			}
+		}()
+	}
+	return outs
+}
+
+// Merge は複数のchannelの値を1つのchannelにまとめる (FanIn)
+// すべての入力が閉じられると出力を閉じる
+func Merge[T any](ctx context.Context, ins ...<-chan T) <-chan T {
+	out := make(chan T)
+	var wg sync.WaitGroup
+	wg.Add(len(ins))
+	for _, in := range ins {
+		go func(in <-chan T) {
+			defer wg.Done()
+			for {
+				v, ok := recv(ctx, in)
+				if !ok || !send(ctx, out, v) {
+					return
+				}
+			}
+		}(in)
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// Tee はinの値をn個のchannelすべてに送る
+// 1つの値をすべてのchannelに送り終わるまで次の値に進まないので、一番遅い読み手に合わせて進む
+func Tee[T any](ctx context.Context, in <-chan T, n int) []<-chan T {
+	if n <= 0 {
+		n = 1
+	}
+	chans := make([]chan T, n)
+	outs := make([]<-chan T, n)
+	for i := range chans {
+		chans[i] = make(chan T)
+		outs[i] = chans[i]
+	}
+	go func() {
+		defer func() {
+			for _, c := range chans {
+				close(c)
+			}
+		}()
+		for {
+			v, ok := recv(ctx, in)
+			if !ok {
+				return
+			}
+			for _, c := range chans {
+				if !send(ctx, c, v) {
+					return
+				}
+			}
+		}
+	}()
+	return outs
+}
+
+// Collect はinが閉じられるかctxが終わるまで値を読んでスライスにして返す
+func Collect[T any](ctx context.Context, in <-chan T) []T {
+	var list []T
+	for {
+		select {
+		case v, ok := <-in:
+			if !ok {
+				return list
+			}
+			list = append(list, v)
+		case <-ctx.Done():
+			return list
+		}
+	}
+}
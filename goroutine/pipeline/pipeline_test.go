@@ -0,0 +1,165 @@
+package pipeline
+
+import (
+	"context"
+	"reflect"
+	"runtime"
+	"sort"
+	"testing"
+	"time"
+)
+
+// ctxが終わるまで0, 1, 2 ... を送り続ける
+func count(ctx context.Context) <-chan int {
+	out := make(chan int)
+	go func() {
+		defer close(out)
+		for i := 0; send(ctx, out, i); i++ {
+		}
+	}()
+	return out
+}
+
+// chが閉じられるまで読み捨てる。timeoutまでに閉じられなければ失敗にする
+func waitClosed[T any](t *testing.T, ch <-chan T, timeout time.Duration) {
+	t.Helper()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case _, ok := <-ch:
+			if !ok {
+				return
+			}
+		case <-timer.C:
+			t.Fatal("channel was not closed")
+		}
+	}
+}
+
+// goroutineの数がbase以下に戻るのを待つ
+func waitGoroutines(t *testing.T, base int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > base {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<16)
+			t.Fatalf("goroutines leaked: %d > %d\n%s", runtime.NumGoroutine(), base, buf[:runtime.Stack(buf, true)])
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestStagesStopOnCancel(t *testing.T) {
+	double := func(ctx context.Context, v int) int { return v * 2 }
+	even := func(ctx context.Context, v int) bool { return v%2 == 0 }
+
+	tests := []struct {
+		name  string
+		stage func(ctx context.Context, in <-chan int) []<-chan int
+	}{
+		{"Map", func(ctx context.Context, in <-chan int) []<-chan int {
+			return []<-chan int{Map(ctx, in, 3, double)}
+		}},
+		{"Filter", func(ctx context.Context, in <-chan int) []<-chan int {
+			return []<-chan int{Filter(ctx, in, 3, even)}
+		}},
+		{"Batch", func(ctx context.Context, in <-chan int) []<-chan int {
+			return []<-chan int{Map(ctx, Batch(ctx, in, 3, time.Second), 1, func(ctx context.Context, b []int) int { return len(b) })}
+		}},
+		{"FanOut", func(ctx context.Context, in <-chan int) []<-chan int {
+			return FanOut(ctx, in, 3)
+		}},
+		{"Merge", func(ctx context.Context, in <-chan int) []<-chan int {
+			return []<-chan int{Merge(ctx, in, count(ctx), count(ctx))}
+		}},
+		{"Tee", func(ctx context.Context, in <-chan int) []<-chan int {
+			return Tee(ctx, in, 3)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := runtime.NumGoroutine()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			outs := tt.stage(ctx, count(ctx))
+			// 下流は1つだけ読んでやめる。他の出力は一度も読まないので、
+			// 上流はどこかのsendで止まっている
+			select {
+			case _, ok := <-outs[0]:
+				if !ok {
+					t.Fatal("closed too early")
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("no value")
+			}
+			cancel()
+
+			for _, out := range outs {
+				waitClosed(t, out, 5*time.Second)
+			}
+			waitGoroutines(t, base)
+		})
+	}
+}
+
+func TestMapFilterCollect(t *testing.T) {
+	ctx := context.Background()
+	src := Source(ctx, 1, 2, 3, 4, 5, 6)
+	odd := Filter(ctx, src, 2, func(ctx context.Context, v int) bool { return v%2 == 1 })
+	squared := Map(ctx, odd, 2, func(ctx context.Context, v int) int { return v * v })
+
+	got := Collect(ctx, squared)
+	sort.Ints(got)
+	if want := []int{1, 9, 25}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestBatchLeftover(t *testing.T) {
+	ctx := context.Background()
+	got := Collect(ctx, Batch(ctx, Source(ctx, 1, 2, 3, 4, 5), 2, 0))
+	if want := [][]int{{1, 2}, {3, 4}, {5}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestBatchMaxWait(t *testing.T) {
+	ctx := context.Background()
+	in := make(chan int)
+	out := Batch(ctx, in, 10, 50*time.Millisecond)
+
+	start := time.Now()
+	in <- 1
+	in <- 2
+	select {
+	case b := <-out:
+		if !reflect.DeepEqual(b, []int{1, 2}) {
+			t.Errorf("got %v", b)
+		}
+		if d := time.Since(start); d < 40*time.Millisecond {
+			t.Errorf("flushed after %v, before maxWait", d)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("not flushed after maxWait")
+	}
+
+	// 次のバッチの時間は次の値から数える
+	in <- 3
+	close(in)
+	if b := <-out; !reflect.DeepEqual(b, []int{3}) {
+		t.Errorf("leftover = %v", b)
+	}
+	waitClosed(t, out, time.Second)
+}
+
+func TestTeeAndMerge(t *testing.T) {
+	ctx := context.Background()
+	outs := Tee(ctx, Source(ctx, 1, 2, 3), 2)
+	got := Collect(ctx, Merge(ctx, outs...))
+	sort.Ints(got)
+	if want := []int{1, 1, 2, 2, 3, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
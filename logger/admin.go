@@ -0,0 +1,200 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Leveler は最低レベルを持つロガー。LoggerとStructuredLoggerが実装している
+type Leveler interface {
+	Level() Level
+	SetLevel(Level)
+}
+
+type registered struct {
+	l        Leveler
+	timer    *time.Timer
+	revertTo Level
+	revertAt time.Time
+}
+
+// LevelRegistry は名前をつけたロガーの最低レベルを実行中に変えるためのもの
+// log.goのDEBUG変数のように再ビルドしなくても、HTTP経由でデバッグ出力をオンにできる
+//
+//	reg := logger.NewLevelRegistry()
+//	reg.Register("app", l)
+//	mux.Handle("/admin/loglevel", reg)
+//
+//	curl localhost:3000/admin/loglevel
+//	curl -X PUT -d '{"name":"app","level":"debug","duration":"5m"}' localhost:3000/admin/loglevel
+type LevelRegistry struct {
+	mu      sync.Mutex
+	loggers map[string]*registered
+}
+
+func NewLevelRegistry() *LevelRegistry {
+	return &LevelRegistry{loggers: map[string]*registered{}}
+}
+
+// Register はlをnameで登録する。同じ名前がある場合は置き換える
+func (r *LevelRegistry) Register(name string, l Leveler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if old, ok := r.loggers[name]; ok && old.timer != nil {
+		old.timer.Stop()
+	}
+	r.loggers[name] = &registered{l: l}
+}
+
+// SetLevel はnameのロガーの最低レベルをlvにする
+// revertが0より大きい場合、その時間が経つと変更前のレベルに戻す
+// 戻す前にもう一度SetLevelした場合は、最初の変更前のレベルに戻す
+func (r *LevelRegistry) SetLevel(name string, lv Level, revert time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reg, ok := r.loggers[name]
+	if !ok {
+		return fmt.Errorf("logger: %q is not registered", name)
+	}
+
+	if reg.timer != nil {
+		reg.timer.Stop()
+		reg.timer = nil
+	} else {
+		reg.revertTo = reg.l.Level()
+	}
+	reg.l.SetLevel(lv)
+	reg.revertAt = time.Time{}
+
+	if revert > 0 {
+		reg.revertAt = time.Now().Add(revert)
+		var timer *time.Timer
+		timer = time.AfterFunc(revert, func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			// 直前にStopされて別のタイマーに置き換わっていたら何もしない
+			if reg.timer != timer {
+				return
+			}
+			reg.l.SetLevel(reg.revertTo)
+			reg.timer = nil
+			reg.revertAt = time.Time{}
+		})
+		reg.timer = timer
+	}
+	return nil
+}
+
+// LevelStatus はServeHTTPが返す1ロガー分の状態
+type LevelStatus struct {
+	Name     string     `json:"name"`
+	Level    Level      `json:"level"`
+	RevertTo *Level     `json:"revert_to,omitempty"`
+	RevertAt *time.Time `json:"revert_at,omitempty"`
+}
+
+func (r *LevelRegistry) status(name string) (LevelStatus, bool) {
+	reg, ok := r.loggers[name]
+	if !ok {
+		return LevelStatus{}, false
+	}
+	s := LevelStatus{Name: name, Level: reg.l.Level()}
+	if reg.timer != nil {
+		revertTo, revertAt := reg.revertTo, reg.revertAt
+		s.RevertTo, s.RevertAt = &revertTo, &revertAt
+	}
+	return s, true
+}
+
+// Status はnameを指定した場合はそのロガー、空の場合はすべてのロガーの状態を名前順で返す
+func (r *LevelRegistry) Status(name string) ([]LevelStatus, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if name != "" {
+		s, ok := r.status(name)
+		if !ok {
+			return nil, fmt.Errorf("logger: %q is not registered", name)
+		}
+		return []LevelStatus{s}, nil
+	}
+
+	names := make([]string, 0, len(r.loggers))
+	for n := range r.loggers {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	list := make([]LevelStatus, 0, len(names))
+	for _, n := range names {
+		s, _ := r.status(n)
+		list = append(list, s)
+	}
+	return list, nil
+}
+
+// Levelのゼロ値はDebugLevelなので、levelが無いときに黙ってdebugにならないようポインタにする
+type levelRequest struct {
+	Name     string `json:"name"`
+	Level    *Level `json:"level"`
+	Duration string `json:"duration"`
+}
+
+// ServeHTTP はGETで状態を返し、PUTで最低レベルを変える
+//   - GET  ?name=app で指定したロガーのみ、nameがなければすべて
+//   - PUT  {"name":"app","level":"debug","duration":"5m"} durationは省略可。指定する場合は0より大きいこと
+func (r *LevelRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		list, err := r.Status(req.URL.Query().Get("name"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		writeJSON(w, list)
+
+	case http.MethodPut:
+		var body levelRequest
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if body.Level == nil {
+			http.Error(w, "logger: level is required", http.StatusBadRequest)
+			return
+		}
+		var revert time.Duration
+		if body.Duration != "" {
+			d, err := time.ParseDuration(body.Duration)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			// "0s"や"-5m"を受け付けると、期限付きのつもりが戻らないままになる
+			if d <= 0 {
+				http.Error(w, "logger: duration must be positive", http.StatusBadRequest)
+				return
+			}
+			revert = d
+		}
+		if err := r.SetLevel(body.Name, *body.Level, revert); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		list, _ := r.Status(body.Name)
+		writeJSON(w, list)
+
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(v)
+}
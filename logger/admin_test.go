@@ -0,0 +1,84 @@
+package logger
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLevelRegistryPut(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		want   Level
+	}{
+		{"set", `{"name":"app","level":"debug"}`, http.StatusOK, DebugLevel},
+		{"missing level", `{"name":"app"}`, http.StatusBadRequest, WarnLevel},
+		{"typo", `{"name":"app","levle":"debug"}`, http.StatusBadRequest, WarnLevel},
+		{"null level", `{"name":"app","level":null}`, http.StatusBadRequest, WarnLevel},
+		{"unknown level", `{"name":"app","level":"verbose"}`, http.StatusBadRequest, WarnLevel},
+		{"bad duration", `{"name":"app","level":"debug","duration":"soon"}`, http.StatusBadRequest, WarnLevel},
+		{"zero duration", `{"name":"app","level":"debug","duration":"0s"}`, http.StatusBadRequest, WarnLevel},
+		{"negative duration", `{"name":"app","level":"debug","duration":"-5m"}`, http.StatusBadRequest, WarnLevel},
+		{"not registered", `{"name":"db","level":"debug"}`, http.StatusNotFound, WarnLevel},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := New(ioutil.Discard, "", 0)
+			l.SetLevel(WarnLevel)
+			reg := NewLevelRegistry()
+			reg.Register("app", l)
+
+			rec := httptest.NewRecorder()
+			reg.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/admin/loglevel", strings.NewReader(tt.body)))
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if l.Level() != tt.want {
+				t.Errorf("level = %v, want %v", l.Level(), tt.want)
+			}
+		})
+	}
+}
+
+func TestLevelRegistryRevert(t *testing.T) {
+	l := New(ioutil.Discard, "", 0)
+	l.SetLevel(WarnLevel)
+	reg := NewLevelRegistry()
+	reg.Register("app", l)
+
+	rec := httptest.NewRecorder()
+	body := `{"name":"app","level":"debug","duration":"50ms"}`
+	reg.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/admin/loglevel", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var list []LevelStatus
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Level != DebugLevel || list[0].RevertTo == nil || *list[0].RevertTo != WarnLevel || list[0].RevertAt == nil {
+		t.Fatalf("status = %+v", list)
+	}
+
+	// 戻る前にもう一度変えても、最初の変更前のレベルに戻る
+	if err := reg.SetLevel("app", InfoLevel, 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for l.Level() != WarnLevel && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if l.Level() != WarnLevel {
+		t.Fatalf("level = %v, want %v", l.Level(), WarnLevel)
+	}
+	list, _ = reg.Status("app")
+	if list[0].RevertTo != nil || list[0].RevertAt != nil {
+		t.Errorf("status after revert = %+v", list[0])
+	}
+}
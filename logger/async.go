@@ -0,0 +1,202 @@
+package logger
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// OverflowPolicy はAsyncWriterとAsyncSinkのキューが一杯だったときの動作
+type OverflowPolicy int
+
+const (
+	// OverflowBlock は空きができるまでWriteを待たせる。ログは失われない
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDrop はその行を捨ててすぐに戻る。捨てた数はDropped()で取れる
+	OverflowDrop
+)
+
+// ErrDropped はキューが一杯で捨てたことを報告するときのエラー
+var ErrDropped = errors.New("logger: queue is full, dropped")
+
+type asyncItem[T any] struct {
+	v       T
+	flushed chan struct{}
+}
+
+// asyncQueue はAsyncWriterとAsyncSinkで共通のキューと書き出し用のgoroutine
+// 積まれた順にwriteを呼び、最後のエラーを覚えておく
+type asyncQueue[T any] struct {
+	write   func(T) error
+	policy  OverflowPolicy
+	queue   chan asyncItem[T]
+	done    chan struct{}
+	dropped uint64
+
+	// closedとqueueへの送信の競合を防ぐ
+	mu     sync.RWMutex
+	closed bool
+
+	errMu sync.Mutex
+	err   error
+}
+
+func newAsyncQueue[T any](size int, policy OverflowPolicy, write func(T) error) *asyncQueue[T] {
+	q := &asyncQueue[T]{
+		write:  write,
+		policy: policy,
+		queue:  make(chan asyncItem[T], size),
+		done:   make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+func (q *asyncQueue[T]) run() {
+	defer close(q.done)
+	for item := range q.queue {
+		if item.flushed != nil {
+			close(item.flushed)
+			continue
+		}
+		if err := q.write(item.v); err != nil {
+			q.errMu.Lock()
+			q.err = err
+			q.errMu.Unlock()
+		}
+	}
+}
+
+// put はvをキューに積む。Close後はos.ErrClosedを返す
+// 書き出しのエラーはここでは返らず、flushかcloseで返る
+func (q *asyncQueue[T]) put(v T) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return os.ErrClosed
+	}
+
+	if q.policy == OverflowDrop {
+		select {
+		case q.queue <- asyncItem[T]{v: v}:
+		default:
+			atomic.AddUint64(&q.dropped, 1)
+		}
+		return nil
+	}
+	q.queue <- asyncItem[T]{v: v}
+	return nil
+}
+
+func (q *asyncQueue[T]) droppedCount() uint64 {
+	return atomic.LoadUint64(&q.dropped)
+}
+
+// flush はそれまでに積んだ分が書き出されるまで待ち、最後のエラーを返す
+func (q *asyncQueue[T]) flush() error {
+	q.mu.RLock()
+	if q.closed {
+		q.mu.RUnlock()
+		return q.lastErr()
+	}
+	flushed := make(chan struct{})
+	q.queue <- asyncItem[T]{flushed: flushed}
+	q.mu.RUnlock()
+
+	<-flushed
+	return q.lastErr()
+}
+
+// close は残っている分をすべて書き出してから終了する
+func (q *asyncQueue[T]) close() error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.queue)
+	}
+	q.mu.Unlock()
+
+	<-q.done
+	return q.lastErr()
+}
+
+func (q *asyncQueue[T]) lastErr() error {
+	q.errMu.Lock()
+	defer q.errMu.Unlock()
+	return q.err
+}
+
+// AsyncWriter は書き込みをキューに積んで別のgoroutineでwに書き出すio.Writer
+// 遅いファイルやネットワークへの書き込みでgoroutineが止まらないようにするためのもの
+//
+// 終了時に書き出していない行が残らないよう、必ずdeferでCloseする
+//
+//	aw := logger.NewAsyncWriter(f, 1024, logger.OverflowBlock)
+//	defer aw.Close()
+//	log.SetOutput(aw)
+type AsyncWriter struct {
+	q *asyncQueue[[]byte]
+}
+
+// NewAsyncWriter はsize行分のキューを持つAsyncWriterを作る
+func NewAsyncWriter(w io.Writer, size int, policy OverflowPolicy) *AsyncWriter {
+	return &AsyncWriter{q: newAsyncQueue(size, policy, func(p []byte) error {
+		_, err := w.Write(p)
+		return err
+	})}
+}
+
+// Write はpをコピーしてキューに積む
+// 実際の書き込みエラーはここでは返らず、FlushかCloseで返る
+func (aw *AsyncWriter) Write(p []byte) (int, error) {
+	// log.Loggerは書き込み後にバッファを使い回すのでコピーしておく
+	b := make([]byte, len(p))
+	copy(b, p)
+	if err := aw.q.put(b); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Dropped はOverflowDropで捨てた行数を返す
+func (aw *AsyncWriter) Dropped() uint64 { return aw.q.droppedCount() }
+
+// Flush はそれまでにWriteした分が書き出されるまで待つ
+// 書き出し中にエラーがあった場合は最後のエラーを返す
+func (aw *AsyncWriter) Flush() error { return aw.q.flush() }
+
+// Close はキューに残っている分をすべて書き出してから終了する
+// wのCloseは呼ばないので、ファイルなどは別途閉じる
+func (aw *AsyncWriter) Close() error { return aw.q.close() }
+
+// AsyncSink はAsyncWriterのSink版。sinkのWriteEntryを別のgoroutineで呼ぶ
+// 遅い出力先(ネットワーク越しのsyslogなど)でログを出す側が止まらないようにするためのもの
+// AsyncWriterと同じく、必ずdeferでCloseする
+type AsyncSink struct {
+	q *asyncQueue[*Entry]
+}
+
+// NewAsyncSink はsize件分のキューを持つAsyncSinkを作る
+func NewAsyncSink(sink Sink, size int, policy OverflowPolicy) *AsyncSink {
+	return &AsyncSink{q: newAsyncQueue(size, policy, sink.WriteEntry)}
+}
+
+// WriteEntry はeをコピーしてキューに積む
+// 実際の書き込みエラーはFlushかCloseで返る
+func (as *AsyncSink) WriteEntry(e *Entry) error {
+	// 呼び出し元がFieldsを使い回しても影響しないようにコピーしておく
+	c := *e
+	c.Fields = append([]Field(nil), e.Fields...)
+	return as.q.put(&c)
+}
+
+// Dropped はOverflowDropで捨てた件数を返す
+func (as *AsyncSink) Dropped() uint64 { return as.q.droppedCount() }
+
+// Flush はそれまでにWriteEntryした分が書き出されるまで待つ
+func (as *AsyncSink) Flush() error { return as.q.flush() }
+
+// Close はキューに残っている分をすべて書き出してから終了する。sinkのCloseは呼ばない
+func (as *AsyncSink) Close() error { return as.q.close() }
@@ -0,0 +1,187 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// writerFunc はテスト用に関数をio.Writerにする
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
+
+// lineWriter は書き込まれた行を記録する。遅い出力先の代わり
+type lineWriter struct {
+	mu    sync.Mutex
+	lines []string
+	delay time.Duration
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	time.Sleep(w.delay)
+	w.mu.Lock()
+	w.lines = append(w.lines, string(p))
+	w.mu.Unlock()
+	return len(p), nil
+}
+
+func (w *lineWriter) written() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]string(nil), w.lines...)
+}
+
+func TestAsyncWriterCloseDrains(t *testing.T) {
+	w := &lineWriter{delay: time.Millisecond}
+	aw := NewAsyncWriter(w, 100, OverflowBlock)
+	buf := make([]byte, 0, 16)
+	for i := 0; i < 50; i++ {
+		// 呼び出し元がバッファを使い回しても影響しない
+		buf = fmt.Appendf(buf[:0], "line %d\n", i)
+		aw.Write(buf)
+	}
+	if err := aw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := w.written()
+	if len(got) != 50 {
+		t.Fatalf("written %d lines, want 50", len(got))
+	}
+	for i, line := range got {
+		if want := fmt.Sprintf("line %d\n", i); line != want {
+			t.Errorf("line %d = %q, want %q", i, line, want)
+		}
+	}
+}
+
+func TestAsyncWriterOverflowDrop(t *testing.T) {
+	release := make(chan struct{})
+	w := &lineWriter{}
+	aw := NewAsyncWriter(writerFunc(func(p []byte) (int, error) {
+		<-release
+		return w.Write(p)
+	}), 1, OverflowDrop)
+
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		if _, err := aw.Write([]byte("spam\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Write blocked for %v", d)
+	}
+	// 1行は書き込み中、1行はキューの中、残りは捨てられる
+	if d := aw.Dropped(); d < 8 {
+		t.Errorf("Dropped() = %d, want >= 8", d)
+	}
+
+	close(release)
+	aw.Close()
+	if n := uint64(len(w.written())); n+aw.Dropped() != 10 {
+		t.Errorf("written %d + dropped %d != 10", n, aw.Dropped())
+	}
+}
+
+func TestAsyncWriterOverflowBlock(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 10)
+	aw := NewAsyncWriter(writerFunc(func(p []byte) (int, error) {
+		started <- struct{}{}
+		<-release
+		return len(p), nil
+	}), 1, OverflowBlock)
+
+	aw.Write([]byte("first\n"))
+	<-started
+	aw.Write([]byte("queued\n"))
+
+	done := make(chan struct{})
+	go func() {
+		aw.Write([]byte("blocked\n"))
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("Write did not block on a full queue")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Write did not resume")
+	}
+	aw.Close()
+	if aw.Dropped() != 0 {
+		t.Errorf("Dropped() = %d, want 0", aw.Dropped())
+	}
+}
+
+func TestAsyncWriterErrors(t *testing.T) {
+	errDisk := errors.New("disk full")
+	aw := NewAsyncWriter(writerFunc(func(p []byte) (int, error) { return 0, errDisk }), 10, OverflowBlock)
+
+	// 書き込みのエラーはWriteではなくFlushで返る
+	if _, err := aw.Write([]byte("spam\n")); err != nil {
+		t.Fatalf("Write = %v", err)
+	}
+	if err := aw.Flush(); !errors.Is(err, errDisk) {
+		t.Errorf("Flush = %v, want %v", err, errDisk)
+	}
+	if err := aw.Close(); !errors.Is(err, errDisk) {
+		t.Errorf("Close = %v, want %v", err, errDisk)
+	}
+
+	if _, err := aw.Write([]byte("spam\n")); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Write after Close = %v, want os.ErrClosed", err)
+	}
+	if err := aw.Flush(); !errors.Is(err, errDisk) {
+		t.Errorf("Flush after Close = %v, want %v", err, errDisk)
+	}
+	if err := aw.Close(); !errors.Is(err, errDisk) {
+		t.Errorf("second Close = %v, want %v", err, errDisk)
+	}
+}
+
+func TestAsyncWriterFlushAfterCloseNoError(t *testing.T) {
+	aw := NewAsyncWriter(&lineWriter{}, 10, OverflowBlock)
+	aw.Close()
+	if err := aw.Flush(); err != nil {
+		t.Errorf("Flush after Close = %v, want nil", err)
+	}
+}
+
+func TestAsyncSink(t *testing.T) {
+	var mu sync.Mutex
+	var got []*Entry
+	as := NewAsyncSink(sinkFunc(func(e *Entry) error {
+		mu.Lock()
+		got = append(got, e)
+		mu.Unlock()
+		return nil
+	}), 10, OverflowBlock)
+
+	fields := []Field{Int("i", 0)}
+	e := &Entry{Message: "spam", Fields: fields}
+	as.WriteEntry(e)
+	// 呼び出し元がFieldsを使い回しても、積んだ分は変わらない
+	fields[0] = Int("i", 1)
+	e.Message = "changed"
+	if err := as.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 1 || got[0].Message != "spam" || got[0].Fields[0].Value != int64(0) {
+		t.Errorf("got %+v", got[0])
+	}
+	if err := as.WriteEntry(e); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("WriteEntry after Close = %v, want os.ErrClosed", err)
+	}
+}
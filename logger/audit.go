@@ -0,0 +1,196 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AuditHashKey は1つ前の行のSHA-256を入れるフィールドのキー
+const AuditHashKey = "prev_hash"
+
+// 最初の行のprev_hash
+var auditGenesis = strings.Repeat("0", sha256.Size*2)
+
+// AuditLogger は追記専用の監査ログ
+// 各行に1つ前の行(改行を除く)のSHA-256を持たせるので、
+// 途中の行を書き換えたり消したりするとVerifyAuditで検出できる
+//
+// 書き込みはRotatingWriter、エンコードはJSONEncoderを使う
+// ローテートするとチェーンが切れるので、RotatingWriterはローテートしない設定で開く
+type AuditLogger struct {
+	mu   sync.Mutex
+	w    *RotatingWriter
+	enc  Encoder
+	prev string
+	// err は書き込みに失敗したときのエラー
+	// 途中まで書かれた行が残っているとチェーンが続かないので、以降は書き込まずにこれを返す
+	err error
+}
+
+// ErrAuditPartialLine は監査ログの最後の行が改行で終わっていないときにOpenAuditが返す
+// 書き込み中に落ちたか書き込みに失敗した跡なので、続きを書く前に人が確認する
+var ErrAuditPartialLine = errors.New("logger: audit: file ends with a partial line")
+
+// OpenAudit はpathを監査ログとして開く
+// 既存のファイルがある場合は最後の行から続きのチェーンを作る
+// 最後の行が改行で終わっていない場合はErrAuditPartialLineを返す
+func OpenAudit(path string, flag int) (*AuditLogger, error) {
+	last, err := lastLine(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	prev := auditGenesis
+	if last != nil {
+		prev = auditHash(last)
+	}
+
+	w, err := NewRotatingWriter(path, RotateConfig{})
+	if err != nil {
+		return nil, err
+	}
+	return &AuditLogger{w: w, enc: NewJSONEncoder(flag), prev: prev}, nil
+}
+
+func auditHash(line []byte) string {
+	sum := sha256.Sum256(line)
+	return hex.EncodeToString(sum[:])
+}
+
+// ファイルの最後の行を改行を除いて返す。空のファイルならnil
+// 改行で終わっていない場合はErrAuditPartialLineを返す
+func lastLine(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	size := info.Size()
+	if size > 0 {
+		b := make([]byte, 1)
+		if _, err := f.ReadAt(b, size-1); err != nil {
+			return nil, err
+		}
+		if b[0] != '\n' {
+			return nil, fmt.Errorf("%w: %s", ErrAuditPartialLine, path)
+		}
+	}
+
+	// 末尾から広げながら読んで、最後の改行より前の改行を探す
+	for chunk := int64(4096); ; chunk *= 2 {
+		if chunk > size {
+			chunk = size
+		}
+		buf := make([]byte, chunk)
+		if _, err := f.ReadAt(buf, size-chunk); err != nil && err != io.EOF {
+			return nil, err
+		}
+		buf = bytes.TrimRight(buf, "\n")
+		if i := bytes.LastIndexByte(buf, '\n'); i >= 0 {
+			return buf[i+1:], nil
+		}
+		if chunk == size {
+			if len(buf) == 0 {
+				return nil, nil
+			}
+			return buf, nil
+		}
+	}
+}
+
+// Log はInfoLevelで1行書き込む
+// 監査ログは書き込めなかったことを知る必要があるのでエラーを返す
+func (a *AuditLogger) Log(msg string, fields ...Field) error {
+	return a.WriteEntry(&Entry{Time: time.Now(), Level: InfoLevel, Message: msg, Fields: fields})
+}
+
+// WriteEntry はeの先頭にprev_hashをつけて書き込む
+// NewStructuredに渡せばレベル付きで使える
+// 一度書き込みに失敗すると、それ以降はすべて同じエラーを返す
+func (a *AuditLogger) WriteEntry(e *Entry) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.err != nil {
+		return a.err
+	}
+
+	c := *e
+	c.Fields = append([]Field{String(AuditHashKey, a.prev)}, e.Fields...)
+	b, err := a.enc.Encode(&c)
+	if err != nil {
+		return err
+	}
+	if _, err := a.w.Write(b); err != nil {
+		a.err = fmt.Errorf("logger: audit: write failed, log is stopped: %w", err)
+		return a.err
+	}
+	a.prev = auditHash(bytes.TrimRight(b, "\n"))
+	return nil
+}
+
+// Head は最後に書き込んだ行のハッシュを返す
+// 末尾の行を消されるとチェーンでは検出できないので、これを別の場所に控えておきVerifyAuditの結果と比べる
+func (a *AuditLogger) Head() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.prev
+}
+
+func (a *AuditLogger) Close() error {
+	return a.w.Close()
+}
+
+// AuditError はVerifyAuditで見つかった不整合
+type AuditError struct {
+	// Line は不整合が見つかった行 (1始まり)
+	Line   int
+	Reason string
+}
+
+func (e *AuditError) Error() string {
+	return fmt.Sprintf("logger: audit: line %d: %s", e.Line, e.Reason)
+}
+
+// VerifyAudit はrの監査ログのチェーンを検証し、行数と最後の行のハッシュを返す
+// n行目のprev_hashが合わない場合は、n-1行目が書き換えられたか、間の行が消されたということ
+func VerifyAudit(r io.Reader) (lines int, head string, err error) {
+	head = auditGenesis
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for sc.Scan() {
+		lines++
+		line := sc.Bytes()
+
+		e, err := ParseEntry(line)
+		if err != nil {
+			return lines, head, &AuditError{Line: lines, Reason: err.Error()}
+		}
+		prev, ok := e.FieldString(AuditHashKey)
+		if !ok {
+			return lines, head, &AuditError{Line: lines, Reason: "missing " + AuditHashKey}
+		}
+		if prev != head {
+			reason := "hash chain broken: previous line was modified or lines were removed"
+			if lines == 1 {
+				reason = "hash chain broken: lines were removed from the beginning"
+			}
+			return lines, head, &AuditError{Line: lines, Reason: reason}
+		}
+		head = auditHash(line)
+	}
+	return lines, head, sc.Err()
+}
@@ -0,0 +1,134 @@
+package logger
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func verifyFile(t *testing.T, path string) (int, string, error) {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	return VerifyAudit(f)
+}
+
+func TestAuditChain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	a, err := OpenAudit(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Log("login", String("user", "graham"))
+	a.Log("delete", String("user", "graham"), Int("id", 1))
+	a.Close()
+
+	// 開き直しても続きのチェーンになる
+	a, err = OpenAudit(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Log("logout", String("user", "graham"))
+	head := a.Head()
+	a.Close()
+
+	lines, got, err := verifyFile(t, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines != 3 || got != head {
+		t.Errorf("lines = %d, head = %s, want 3, %s", lines, got, head)
+	}
+}
+
+func TestVerifyAuditTampered(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	a, err := OpenAudit(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, msg := range []string{"one", "two", "three"} {
+		a.Log(msg)
+	}
+	a.Close()
+	orig := readFile(t, path)
+	lines := strings.SplitAfter(orig, "\n")
+
+	tests := []struct {
+		name     string
+		content  string
+		wantLine int
+	}{
+		{"modified", strings.Replace(orig, `"msg":"two"`, `"msg":"TWO"`, 1), 3},
+		{"removed middle", lines[0] + lines[2], 2},
+		{"removed first", lines[1] + lines[2], 1},
+		{"not json", lines[0] + "garbage\n" + lines[1], 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := VerifyAudit(strings.NewReader(tt.content))
+			var ae *AuditError
+			if !errors.As(err, &ae) {
+				t.Fatalf("err = %v, want AuditError", err)
+			}
+			if ae.Line != tt.wantLine {
+				t.Errorf("Line = %d, want %d", ae.Line, tt.wantLine)
+			}
+		})
+	}
+}
+
+func TestOpenAuditPartialLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	a, err := OpenAudit(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Log("one")
+	a.Close()
+
+	// 書き込み途中で落ちた状態
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	f.WriteString(`{"level":"info","prev_`)
+	f.Close()
+
+	if _, err := OpenAudit(path, 0); !errors.Is(err, ErrAuditPartialLine) {
+		t.Fatalf("OpenAudit = %v, want ErrAuditPartialLine", err)
+	}
+	if b, _ := ioutil.ReadFile(path); !strings.HasSuffix(string(b), `"prev_`) {
+		t.Errorf("file was modified: %q", b)
+	}
+}
+
+func TestAuditWriteErrorStops(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	a, err := OpenAudit(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	a.Log("one")
+	head := a.Head()
+
+	// 下のファイルを閉じて書き込みを失敗させる
+	a.w.file.Close()
+	if err := a.Log("two"); err == nil {
+		t.Fatal("Log should fail")
+	}
+	// RotatingWriterは開き直せるが、監査ログは止まったまま
+	if err := a.Log("three"); err == nil {
+		t.Fatal("Log after a write error should fail")
+	}
+	if a.Head() != head {
+		t.Error("Head changed after a failed write")
+	}
+	if _, got, err := verifyFile(t, path); err != nil || got != head {
+		t.Errorf("verify = %s, %v", got, err)
+	}
+}
@@ -0,0 +1,84 @@
+package logger
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Caller はログを出力した場所
+type Caller struct {
+	File     string
+	Line     int
+	Function string
+}
+
+// String はlog.Lshortfileより少し長い "パッケージのディレクトリ/ファイル名:行" を返す
+// main.goのように同じファイル名が複数のパッケージにあっても区別できるように
+func (c *Caller) String() string {
+	file := c.File
+	if i := strings.LastIndexByte(file, '/'); i >= 0 {
+		if j := strings.LastIndexByte(file[:i], '/'); j >= 0 {
+			file = file[j+1:]
+		}
+	}
+	if c.Line == 0 {
+		return file
+	}
+	return file + ":" + strconv.Itoa(c.Line)
+}
+
+// AddCaller はEntryに呼び出し元のファイル名・行・関数名をつけるロガーを返す
+// printf形式のLoggerの場合はlog.Lshortfileを使えばいい
+func (l *StructuredLogger) AddCaller() *StructuredLogger {
+	c := *l
+	c.addCaller = true
+	return &c
+}
+
+// AddCallerSkip は呼び出し元をskip段だけ遡るロガーを返す
+// ロガーをラップした関数を作った場合、そのままだとラッパーの中が呼び出し元になってしまうので、
+// ラッパーの段数だけ指定する
+//
+//	func logError(err error) { l.AddCallerSkip(1).Error("failed", logger.Err(err)) }
+func (l *StructuredLogger) AddCallerSkip(skip int) *StructuredLogger {
+	c := *l
+	c.callerSkip += skip
+	return &c
+}
+
+// WithStacktrace はlv以上のEntryにスタックトレースをつけるロガーを返す
+// デフォルトはErrorLevelで、AddCallerしていなくてもつく
+// つけたくない場合はFatalLevel+1を指定する
+func (l *StructuredLogger) WithStacktrace(lv Level) *StructuredLogger {
+	c := *l
+	c.stackLevel = lv
+	return &c
+}
+
+func callerAt(skip int) *Caller {
+	pc := make([]uintptr, 1)
+	if runtime.Callers(skip+1, pc) == 0 {
+		return nil
+	}
+	frame, _ := runtime.CallersFrames(pc).Next()
+	return &Caller{File: frame.File, Line: frame.Line, Function: frame.Function}
+}
+
+// panic時の出力と同じく 関数名\n\tファイル:行 を繰り返した形にする
+func stackAt(skip int) string {
+	pc := make([]uintptr, 64)
+	n := runtime.Callers(skip+1, pc)
+	frames := runtime.CallersFrames(pc[:n])
+
+	sb := &strings.Builder{}
+	for {
+		frame, more := frames.Next()
+		fmt.Fprintf(sb, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
@@ -0,0 +1,71 @@
+package logger
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStructuredLoggerStack(t *testing.T) {
+	tests := []struct {
+		name      string
+		logger    func(Sink) *StructuredLogger
+		log       func(l *StructuredLogger)
+		wantStack bool
+	}{
+		{"error without AddCaller", NewStructured, func(l *StructuredLogger) { l.Error("e") }, true},
+		{"info", NewStructured, func(l *StructuredLogger) { l.Info("i") }, false},
+		{"warn with WithStacktrace", func(s Sink) *StructuredLogger { return NewStructured(s).WithStacktrace(WarnLevel) },
+			func(l *StructuredLogger) { l.Warn("w") }, true},
+		{"disabled", func(s Sink) *StructuredLogger { return NewStructured(s).WithStacktrace(FatalLevel + 1) },
+			func(l *StructuredLogger) { l.Error("e") }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *Entry
+			tt.log(tt.logger(sinkFunc(func(e *Entry) error { got = e; return nil })))
+			if got == nil {
+				t.Fatal("no entry")
+			}
+			if got.Caller != nil {
+				t.Errorf("Caller = %v without AddCaller", got.Caller)
+			}
+			if !tt.wantStack {
+				if got.Stack != "" {
+					t.Errorf("unexpected stack:\n%s", got.Stack)
+				}
+				return
+			}
+			// 先頭はログを出した関数で、ロガーの中は含まない
+			first := strings.SplitN(got.Stack, "\n", 2)[0]
+			if !strings.Contains(first, "TestStructuredLoggerStack") {
+				t.Errorf("stack starts with %q:\n%s", first, got.Stack)
+			}
+			if strings.Contains(got.Stack, "StructuredLogger).log") {
+				t.Errorf("stack contains logger internals:\n%s", got.Stack)
+			}
+		})
+	}
+}
+
+func TestStructuredLoggerAddCaller(t *testing.T) {
+	var got *Entry
+	l := NewStructured(sinkFunc(func(e *Entry) error { got = e; return nil })).AddCaller()
+	l.Info("i")
+	if got.Caller == nil || !strings.HasSuffix(got.Caller.File, "caller_test.go") {
+		t.Fatalf("Caller = %+v", got.Caller)
+	}
+	if !strings.HasSuffix(got.Caller.Function, "TestStructuredLoggerAddCaller") {
+		t.Errorf("Function = %s", got.Caller.Function)
+	}
+}
+
+func TestSyslogFormatStack(t *testing.T) {
+	s := &SyslogSink{conf: SyslogConfig{Hostname: "host", AppName: "app", MsgID: "-"}, pid: "1"}
+	var got *Entry
+	NewStructured(sinkFunc(func(e *Entry) error { got = e; return nil })).Error("failed", Int("id", 1))
+
+	msg := string(s.Format(got))
+	if !strings.Contains(msg, `id="1" stack="`) || !strings.Contains(msg, "TestSyslogFormatStack") {
+		t.Errorf("stack missing from syslog message: %s", msg)
+	}
+}
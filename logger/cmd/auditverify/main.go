@@ -0,0 +1,47 @@
+/*
+logger.AuditLoggerで書き出した監査ログのハッシュチェーンを検証するコマンド
+
+go run ./logger/cmd/auditverify -expect 3f1a... audit.log
+
+最後の行を消されたことはチェーンだけでは分からないので、
+AuditLogger.Head()を別の場所に控えておき-expectで渡す
+
+doc:
+  - https://pkg.go.dev/crypto/sha256
+*/
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/nc30/golang_examples/logger"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("auditverify: ")
+
+	expect := flag.String("expect", "", "expected hash of the last line")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		log.Fatal("usage: auditverify [-expect hash] file")
+	}
+
+	f, err := os.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	lines, head, err := logger.VerifyAudit(f)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *expect != "" && *expect != head {
+		log.Fatalf("last line hash is %s, expected %s: lines were removed from the end", head, *expect)
+	}
+	log.Printf("ok: %d lines, head %s", lines, head)
+}
@@ -0,0 +1,241 @@
+/*
+loggerパッケージで出力したjson/logfmtのログを検索するコマンド
+
+go run ./logger/cmd/logquery -level warn -since "2022-01-25 12:00" -tz Asia/Tokyo -field url=https://httpbin.org/get app.log
+
+-rotated をつけるとapp.log.1, app.log.2.gzのようなローテート済みのファイルも古い順に読む
+ファイルを指定しなければ標準入力から読む
+
+doc:
+  - https://pkg.go.dev/flag
+  - https://pkg.go.dev/compress/gzip
+*/
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nc30/golang_examples/logger"
+)
+
+// -field key=value を複数指定できるようにする
+type fieldFlags [][2]string
+
+func (f *fieldFlags) String() string { return fmt.Sprint(*f) }
+
+func (f *fieldFlags) Set(s string) error {
+	i := strings.IndexByte(s, '=')
+	if i <= 0 {
+		return fmt.Errorf("expected key=value, got %q", s)
+	}
+	*f = append(*f, [2]string{s[:i], s[i+1:]})
+	return nil
+}
+
+// time/basic.goのようにタイムゾーンを指定して時刻を読む
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006/01/02 15:04:05",
+}
+
+func parseTime(s string, loc *time.Location) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unknown time format %q", s)
+}
+
+type query struct {
+	level  logger.Level
+	since  time.Time
+	until  time.Time
+	fields fieldFlags
+}
+
+func (q *query) match(e *logger.Entry) bool {
+	if e.Level < q.level {
+		return false
+	}
+	if !q.since.IsZero() && (e.Time.IsZero() || e.Time.Before(q.since)) {
+		return false
+	}
+	if !q.until.IsZero() && (e.Time.IsZero() || !e.Time.Before(q.until)) {
+		return false
+	}
+	for _, kv := range q.fields {
+		if kv[0] == "msg" {
+			if e.Message != kv[1] {
+				return false
+			}
+			continue
+		}
+		if v, ok := e.FieldString(kv[0]); !ok || v != kv[1] {
+			return false
+		}
+	}
+	return true
+}
+
+// -rotatedの場合は path.N(.gz) ... path.1(.gz), path の順にする
+func expand(path string, rotated bool) []string {
+	if !rotated {
+		return []string{path}
+	}
+	var backups []string
+	for i := 1; ; i++ {
+		name := path + "." + strconv.Itoa(i)
+		if _, err := os.Stat(name); err == nil {
+			backups = append(backups, name)
+			continue
+		}
+		if _, err := os.Stat(name + ".gz"); err == nil {
+			backups = append(backups, name+".gz")
+			continue
+		}
+		break
+	}
+	paths := make([]string, 0, len(backups)+1)
+	for i := len(backups) - 1; i >= 0; i-- {
+		paths = append(paths, backups[i])
+	}
+	return append(paths, path)
+}
+
+func open(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(path, ".gz") {
+		return f, nil
+	}
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{zr, f}, nil
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("logquery: ")
+
+	var (
+		q       query
+		fields  fieldFlags
+		level   = flag.String("level", "debug", "minimum level (debug, info, warn, error, fatal)")
+		since   = flag.String("since", "", "show entries at or after this time")
+		until   = flag.String("until", "", "show entries before this time")
+		tz      = flag.String("tz", "Local", "timezone for -since/-until and console output (e.g. UTC, Asia/Tokyo)")
+		output  = flag.String("output", "console", "output format (console, json, logfmt)")
+		rotated = flag.Bool("rotated", false, "also read rotated generations (path.1, path.2.gz, ...)")
+	)
+	flag.Var(&fields, "field", "key=value to match (repeatable)")
+	flag.Parse()
+
+	loc, err := time.LoadLocation(*tz)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if q.level, err = logger.ParseLevel(*level); err != nil {
+		log.Fatal(err)
+	}
+	if *since != "" {
+		if q.since, err = parseTime(*since, loc); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *until != "" {
+		if q.until, err = parseTime(*until, loc); err != nil {
+			log.Fatal(err)
+		}
+	}
+	q.fields = fields
+
+	var enc logger.Encoder
+	switch *output {
+	case "console":
+		enc = logger.NewConsoleEncoder(os.Stdout, log.Ldate|log.Lmicroseconds, "")
+	case "json":
+		enc = logger.NewJSONEncoder(log.Ldate | log.Lmicroseconds)
+	case "logfmt":
+		enc = logger.NewLogfmtEncoder(log.Ldate | log.Lmicroseconds)
+	default:
+		log.Fatalf("unknown output %q", *output)
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+
+	var paths []string
+	for _, arg := range flag.Args() {
+		paths = append(paths, expand(arg, *rotated)...)
+	}
+
+	skipped := 0
+	run := func(name string, r io.Reader) {
+		sc := bufio.NewScanner(r)
+		// スタックトレース付きの行は長くなるので余裕を持たせる
+		sc.Buffer(make([]byte, 64*1024), 4*1024*1024)
+		for sc.Scan() {
+			if len(strings.TrimSpace(sc.Text())) == 0 {
+				continue
+			}
+			e, err := logger.ParseEntry(sc.Bytes())
+			if err != nil {
+				skipped++
+				continue
+			}
+			if !q.match(e) {
+				continue
+			}
+			if !e.Time.IsZero() {
+				e.Time = e.Time.In(loc)
+			}
+			b, err := enc.Encode(e)
+			if err != nil {
+				log.Fatal(err)
+			}
+			out.Write(b)
+		}
+		if err := sc.Err(); err != nil {
+			log.Printf("%s: %v", name, err)
+		}
+	}
+
+	if len(paths) == 0 {
+		run("stdin", os.Stdin)
+	}
+	for _, path := range paths {
+		r, err := open(path)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		run(path, r)
+		r.Close()
+	}
+
+	if skipped > 0 {
+		out.Flush()
+		log.Printf("skipped %d unparsable lines", skipped)
+	}
+}
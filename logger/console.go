@@ -0,0 +1,187 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// ConsoleEncoder は開発中にターミナルで読むための出力をする
+//
+//	[stdout] 2021/11/11 11:42:38 INFO  I sleep all night  id=123 name=Graham
+//
+// プレフィックスはPrefixWidthに揃えるので、プレフィックスの違うロガーが混ざっても列がずれない
+type ConsoleEncoder struct {
+	// Flags はlog.LstdFlagsなどのlogパッケージのフラグ。時刻の表記はlogパッケージと同じ
+	Flags int
+	// Prefix はlog.SetPrefixと同じ。log.Prefix()を渡せば標準のロガーに合わせられる
+	Prefix string
+	// PrefixWidth はプレフィックスの表示幅。足りない分をスペースで埋める
+	PrefixWidth int
+	// Color がtrueならレベルとキーに色をつける
+	Color bool
+}
+
+// NewConsoleEncoder はwに書き出すためのConsoleEncoderを作る
+// wがターミナルでない場合やNO_COLORが設定されている場合は色をつけない
+func NewConsoleEncoder(w io.Writer, flag int, prefix string) *ConsoleEncoder {
+	return &ConsoleEncoder{
+		Flags:       flag,
+		Prefix:      prefix,
+		PrefixWidth: displayWidth(prefix),
+		Color:       UseColor(w),
+	}
+}
+
+// UseColor はwに色付きで出力してよいかを返す
+// https://no-color.org/
+func UseColor(w io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+const (
+	colorReset = "\x1b[0m"
+	colorDim   = "\x1b[2m"
+)
+
+var levelColors = [levelCount]string{
+	DebugLevel: "\x1b[35m",   // magenta
+	InfoLevel:  "\x1b[36m",   // cyan
+	WarnLevel:  "\x1b[33m",   // yellow
+	ErrorLevel: "\x1b[31m",   // red
+	FatalLevel: "\x1b[1;31m", // bold red
+}
+
+func (enc *ConsoleEncoder) Encode(e *Entry) ([]byte, error) {
+	buf := &bytes.Buffer{}
+
+	if enc.Prefix != "" || enc.PrefixWidth > 0 {
+		buf.WriteString(enc.Prefix)
+		if pad := enc.PrefixWidth - displayWidth(enc.Prefix); pad > 0 {
+			buf.WriteString(strings.Repeat(" ", pad))
+		}
+	}
+	if ts := formatLogTime(e.Time, enc.Flags); ts != "" {
+		enc.colored(buf, colorDim, ts)
+		buf.WriteByte(' ')
+	}
+
+	lv := e.Level.String()
+	color := ""
+	if e.Level >= DebugLevel && e.Level <= FatalLevel {
+		color = levelColors[e.Level]
+	}
+	enc.colored(buf, color, lv)
+	if pad := 5 - len(lv); pad > 0 {
+		buf.WriteString(strings.Repeat(" ", pad))
+	}
+	buf.WriteByte(' ')
+	if e.Caller != nil {
+		enc.colored(buf, colorDim, e.Caller.String())
+		buf.WriteByte(' ')
+	}
+	buf.WriteString(e.Message)
+
+	if len(e.Fields) > 0 {
+		buf.WriteString(" ")
+	}
+	for _, f := range e.Fields {
+		buf.WriteByte(' ')
+		enc.colored(buf, colorDim, f.Key+"=")
+		buf.WriteString(consoleValue(f.Value))
+	}
+	buf.WriteByte('\n')
+	if e.Stack != "" {
+		// panic時と同じ見た目になるようにそのまま改行して出す
+		enc.colored(buf, colorDim, e.Stack)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+func (enc *ConsoleEncoder) colored(buf *bytes.Buffer, color, s string) {
+	if !enc.Color || color == "" {
+		buf.WriteString(s)
+		return
+	}
+	buf.WriteString(color)
+	buf.WriteString(s)
+	buf.WriteString(colorReset)
+}
+
+// 文字列や数値はlogfmtと同じ、構造体やmapはjsonで出力する
+// %+vだとネストしたときに読みづらいため
+func consoleValue(v interface{}) string {
+	switch v.(type) {
+	case nil, string, bool, int64, uint64, float64, time.Duration, time.Time, error:
+		buf := &bytes.Buffer{}
+		appendLogfmtValue(buf, logfmtString(v))
+		return buf.String()
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return logfmtString(v)
+	}
+	return string(b)
+}
+
+// logパッケージと同じ書式で時刻を組み立てる。ゼロ値の場合は出力しない
+func formatLogTime(t time.Time, flag int) string {
+	if t.IsZero() {
+		return ""
+	}
+	if flag&log.LUTC != 0 {
+		t = t.UTC()
+	}
+	var parts []string
+	if flag&log.Ldate != 0 {
+		parts = append(parts, t.Format("2006/01/02"))
+	}
+	if flag&(log.Ltime|log.Lmicroseconds) != 0 {
+		if flag&log.Lmicroseconds != 0 {
+			parts = append(parts, t.Format("15:04:05.000000"))
+		} else {
+			parts = append(parts, t.Format("15:04:05"))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// 日本語などの全角文字は2文字分として数える
+func displayWidth(s string) int {
+	w := 0
+	for _, r := range s {
+		if isWide(r) {
+			w += 2
+		} else {
+			w++
+		}
+	}
+	return w
+}
+
+func isWide(r rune) bool {
+	return (r >= 0x1100 && r <= 0x115f) ||
+		(r >= 0x2e80 && r <= 0xa4cf && r != 0x303f) ||
+		(r >= 0xac00 && r <= 0xd7a3) ||
+		(r >= 0xf900 && r <= 0xfaff) ||
+		(r >= 0xfe30 && r <= 0xfe4f) ||
+		(r >= 0xff00 && r <= 0xff60) ||
+		(r >= 0xffe0 && r <= 0xffe6) ||
+		(r >= 0x20000 && r <= 0x3fffd)
+}
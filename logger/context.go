@@ -0,0 +1,85 @@
+package logger
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+)
+
+var (
+	defaultMu     sync.RWMutex
+	defaultLogger = NewJSON(os.Stderr, log.LstdFlags)
+)
+
+// Default はFromContextでロガーが見つからなかったときに使うロガーを返す
+// 初期値は標準エラー出力にjsonを書き出すもの
+func Default() *StructuredLogger {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultLogger
+}
+
+// SetDefault はDefaultが返すロガーを変える
+func SetDefault(l *StructuredLogger) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultLogger = l
+}
+
+// context/store.goでは"silly"のような文字列をキーにしていたが、
+// それだと他のパッケージと衝突する恐れがあるので非公開の型をキーにする
+type contextKey struct{}
+
+// WithContext はlをctxに入れる
+func WithContext(ctx context.Context, l *StructuredLogger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext はWithContextで入れたロガーを取り出す。無い場合はDefault()
+func FromContext(ctx context.Context) *StructuredLogger {
+	if l, ok := ctx.Value(contextKey{}).(*StructuredLogger); ok {
+		return l
+	}
+	return Default()
+}
+
+// WithFields はctxのロガーにfieldsを追加したものをctxに入れ直す
+// 呼び出し先ではFromContext(ctx)で取り出して使うだけでfieldsが出力される
+//
+//	ctx = logger.WithFields(ctx, logger.String("user_id", uid))
+//	...
+//	logger.FromContext(ctx).Info("updated") // {"level":"info","msg":"updated","request_id":"...","user_id":"..."}
+func WithFields(ctx context.Context, fields ...Field) context.Context {
+	return WithContext(ctx, FromContext(ctx).With(fields...))
+}
+
+// RequestIDHeader はMiddlewareがリクエストIDの受け渡しに使うヘッダ
+const RequestIDHeader = "X-Request-Id"
+
+// Middleware はリクエストごとにrequest_idをつけたlをr.Context()に入れるミドルウェア
+// X-Request-Idヘッダがあればその値を、無ければランダムな値を使い、レスポンスヘッダにも返す
+// net/httpのServeMuxならMiddleware(l)(mux)、chiならr.Use(Middleware(l))で使う
+func Middleware(l *StructuredLogger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(RequestIDHeader)
+			if id == "" {
+				id = newRequestID()
+			}
+			w.Header().Set(RequestIDHeader, id)
+
+			ctx := WithContext(r.Context(), l.With(String("request_id", id)))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,97 @@
+package logger
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// entrySink は受け取ったEntryを記録する
+type entrySink struct {
+	mu      sync.Mutex
+	entries []*Entry
+}
+
+func (s *entrySink) WriteEntry(e *Entry) error {
+	s.mu.Lock()
+	s.entries = append(s.entries, e)
+	s.mu.Unlock()
+	return nil
+}
+
+func fieldValue(e *Entry, key string) (interface{}, bool) {
+	for _, f := range e.Fields {
+		if f.Key == key {
+			return f.Value, true
+		}
+	}
+	return nil, false
+}
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+	}{
+		{"from header", "abc-123"},
+		{"generated", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := &entrySink{}
+			h := Middleware(NewStructured(sink))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ctx := WithFields(r.Context(), String("user_id", "graham"))
+				FromContext(ctx).Info("handled")
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(RequestIDHeader, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			id := rec.Header().Get(RequestIDHeader)
+			if tt.header != "" && id != tt.header {
+				t.Errorf("response id = %q, want %q", id, tt.header)
+			}
+			if tt.header == "" && len(id) != 16 {
+				t.Errorf("generated id = %q", id)
+			}
+
+			if len(sink.entries) != 1 {
+				t.Fatalf("got %d entries, want 1", len(sink.entries))
+			}
+			e := sink.entries[0]
+			if v, _ := fieldValue(e, "request_id"); v != id {
+				t.Errorf("request_id = %v, want %q", v, id)
+			}
+			if v, _ := fieldValue(e, "user_id"); v != "graham" {
+				t.Errorf("user_id = %v", v)
+			}
+		})
+	}
+}
+
+// リクエストごとに別のIDになる
+func TestMiddlewareUniqueIDs(t *testing.T) {
+	h := Middleware(NewStructured(&entrySink{}))(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	seen := map[string]bool{}
+	for i := 0; i < 10; i++ {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		id := rec.Header().Get(RequestIDHeader)
+		if seen[id] {
+			t.Fatalf("duplicate id %q", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestFromContextDefault(t *testing.T) {
+	if FromContext(context.Background()) != Default() {
+		t.Error("FromContext without a logger should return Default()")
+	}
+}
@@ -0,0 +1,164 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// Encoder はEntryを改行付きの1行に変換する
+type Encoder interface {
+	Encode(e *Entry) ([]byte, error)
+}
+
+// 時刻の表記はlog.SetFlagsと同じフラグで決める
+//   - Ldate, Ltime, Lmicrosecondsのどれも無い場合と、時刻がゼロ値の場合は時刻を出力しない
+//   - LUTCがあればUTCで出力する
+//   - Lmicrosecondsがあればマイクロ秒まで出力する
+const (
+	timeLayout      = time.RFC3339
+	timeLayoutMicro = "2006-01-02T15:04:05.000000Z07:00"
+)
+
+func formatTime(t time.Time, flag int) (string, bool) {
+	if flag&(log.Ldate|log.Ltime|log.Lmicroseconds) == 0 || t.IsZero() {
+		return "", false
+	}
+	if flag&log.LUTC != 0 {
+		t = t.UTC()
+	}
+	if flag&log.Lmicroseconds != 0 {
+		return t.Format(timeLayoutMicro), true
+	}
+	return t.Format(timeLayout), true
+}
+
+// JSONEncoder は1行に1つのjsonオブジェクトを出力する
+//
+//	{"time":"2021-01-19T18:57:09.086480+09:00","level":"info","msg":"spam","id":123,"name":"Graham"}
+type JSONEncoder struct {
+	// Flags はlog.LstdFlagsなどのlogパッケージのフラグ
+	Flags int
+}
+
+func NewJSONEncoder(flag int) *JSONEncoder {
+	return &JSONEncoder{Flags: flag}
+}
+
+func (enc *JSONEncoder) Encode(e *Entry) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteByte('{')
+	if ts, ok := formatTime(e.Time, enc.Flags); ok {
+		buf.WriteString(`"time":`)
+		appendJSONString(buf, ts)
+		buf.WriteByte(',')
+	}
+	buf.WriteString(`"level":`)
+	appendJSONString(buf, levelKey(e.Level))
+	buf.WriteString(`,"msg":`)
+	appendJSONString(buf, e.Message)
+	if e.Caller != nil {
+		buf.WriteString(`,"caller":`)
+		appendJSONString(buf, e.Caller.String())
+		buf.WriteString(`,"func":`)
+		appendJSONString(buf, e.Caller.Function)
+	}
+	if e.Stack != "" {
+		buf.WriteString(`,"stack":`)
+		appendJSONString(buf, e.Stack)
+	}
+	for _, f := range e.Fields {
+		buf.WriteByte(',')
+		appendJSONString(buf, f.Key)
+		buf.WriteByte(':')
+		appendJSONValue(buf, f.Value)
+	}
+	buf.WriteString("}\n")
+	return buf.Bytes(), nil
+}
+
+func levelKey(lv Level) string {
+	b, _ := lv.MarshalText()
+	return string(b)
+}
+
+func appendJSONValue(buf *bytes.Buffer, v interface{}) {
+	switch val := v.(type) {
+	case nil:
+		buf.WriteString("null")
+	case string:
+		appendJSONString(buf, val)
+	case bool:
+		buf.WriteString(strconv.FormatBool(val))
+	case int64:
+		buf.WriteString(strconv.FormatInt(val, 10))
+	case uint64:
+		buf.WriteString(strconv.FormatUint(val, 10))
+	case float64:
+		// jsonではNaNやInfを表現できないので文字列にする
+		if math.IsNaN(val) || math.IsInf(val, 0) {
+			appendJSONString(buf, strconv.FormatFloat(val, 'g', -1, 64))
+		} else {
+			buf.WriteString(strconv.FormatFloat(val, 'g', -1, 64))
+		}
+	case time.Duration:
+		appendJSONString(buf, val.String())
+	case time.Time:
+		appendJSONString(buf, val.Format(time.RFC3339Nano))
+	case error:
+		appendJSONString(buf, val.Error())
+	default:
+		b, err := json.Marshal(val)
+		if err != nil {
+			// Marshalできないもの(chanなど)はとりあえず%+vで文字列にしておく
+			appendJSONString(buf, fmt.Sprintf("%+v", val))
+			return
+		}
+		buf.Write(b)
+	}
+}
+
+const hexDigits = "0123456789abcdef"
+
+// json.Marshalだと<>&までエスケープされるので自前で書く
+// 日本語などのマルチバイト文字はそのまま出力する
+func appendJSONString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			switch {
+			case c == '"' || c == '\\':
+				buf.WriteByte('\\')
+				buf.WriteByte(c)
+			case c == '\n':
+				buf.WriteString(`\n`)
+			case c == '\r':
+				buf.WriteString(`\r`)
+			case c == '\t':
+				buf.WriteString(`\t`)
+			case c < 0x20:
+				buf.WriteString(`\u00`)
+				buf.WriteByte(hexDigits[c>>4])
+				buf.WriteByte(hexDigits[c&0xf])
+			default:
+				buf.WriteByte(c)
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			buf.WriteString(`�`)
+		} else {
+			buf.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	buf.WriteByte('"')
+}
@@ -0,0 +1,92 @@
+package logger
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"testing"
+	"time"
+)
+
+var testTime = time.Date(2021, 1, 19, 18, 57, 9, 86480000, time.FixedZone("JST", 9*60*60))
+
+func TestJSONEncoder(t *testing.T) {
+	tests := []struct {
+		name  string
+		flags int
+		entry Entry
+		want  string
+	}{
+		{
+			name:  "no time",
+			entry: Entry{Level: InfoLevel, Message: "spam"},
+			want:  `{"level":"info","msg":"spam"}`,
+		},
+		{
+			name:  "time",
+			flags: log.LstdFlags,
+			entry: Entry{Time: testTime, Level: WarnLevel, Message: "spam"},
+			want:  `{"time":"2021-01-19T18:57:09+09:00","level":"warn","msg":"spam"}`,
+		},
+		{
+			name:  "utc microseconds",
+			flags: log.LstdFlags | log.Lmicroseconds | log.LUTC,
+			entry: Entry{Time: testTime, Level: ErrorLevel, Message: "spam"},
+			want:  `{"time":"2021-01-19T09:57:09.086480Z","level":"error","msg":"spam"}`,
+		},
+		{
+			name: "fields",
+			entry: Entry{Level: DebugLevel, Message: "spam", Fields: []Field{
+				Int("id", 123), String("name", "Graham"), Bool("ok", true), Uint64("u", 1<<63),
+				Float64("f", 1.5), Duration("d", 1500*time.Millisecond), Time("t", testTime),
+			}},
+			want: `{"level":"debug","msg":"spam","id":123,"name":"Graham","ok":true,"u":9223372036854775808,"f":1.5,"d":"1.5s","t":"2021-01-19T18:57:09.08648+09:00"}`,
+		},
+		{
+			name:  "errors",
+			entry: Entry{Level: ErrorLevel, Message: "spam", Fields: []Field{Err(errors.New("boom")), Err(nil)}},
+			want:  `{"level":"error","msg":"spam","error":"boom","error":null}`,
+		},
+		{
+			name:  "nan",
+			entry: Entry{Level: InfoLevel, Message: "spam", Fields: []Field{Float64("nan", math.NaN()), Float64("inf", math.Inf(1))}},
+			want:  `{"level":"info","msg":"spam","nan":"NaN","inf":"+Inf"}`,
+		},
+		{
+			name:  "any",
+			entry: Entry{Level: InfoLevel, Message: "spam", Fields: []Field{Any("m", map[string]int{"a": 1}), Any("s", []string{"x"})}},
+			want:  `{"level":"info","msg":"spam","m":{"a":1},"s":["x"]}`,
+		},
+		{
+			name:  "escape",
+			entry: Entry{Level: InfoLevel, Message: "a\"b\\c\nd\te\x01<&> 日本語\xff"},
+			want:  `{"level":"info","msg":"a\"b\\c\nd\te\u0001<&> 日本語` + "�" + `"}`,
+		},
+		{
+			name:  "caller and stack",
+			entry: Entry{Level: ErrorLevel, Message: "spam", Caller: &Caller{File: "/src/app/main.go", Line: 10, Function: "main.main"}, Stack: "main.main\n\t/src/app/main.go:10"},
+			want:  `{"level":"error","msg":"spam","caller":"app/main.go:10","func":"main.main","stack":"main.main\n\t/src/app/main.go:10"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := NewJSONEncoder(tt.flags).Encode(&tt.entry)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := string(b)
+			if got[len(got)-1] != '\n' {
+				t.Errorf("no trailing newline: %q", got)
+			}
+			got = got[:len(got)-1]
+
+			if got != tt.want {
+				t.Errorf("\n got %s\nwant %s", got, tt.want)
+			}
+			if !json.Valid(b) {
+				t.Errorf("invalid json: %s", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package logger
+
+import (
+	"time"
+)
+
+// Entry は構造化ログの1行分
+type Entry struct {
+	Time    time.Time
+	Level   Level
+	Message string
+	Fields  []Field
+
+	// Caller はStructuredLogger.AddCaller()を使ったときだけつく
+	// Stack はErrorLevel以上(WithStacktraceで変更可)のときにつく
+	Caller *Caller
+	Stack  string
+}
+
+// Field はEntryにつけるkey/valueの組
+// Valueの型によってエンコーダーでの出力形式が変わるので、
+// なるべく下のString()やInt()などを使って作る
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+func String(key, value string) Field { return Field{key, value} }
+
+func Int(key string, value int) Field { return Field{key, int64(value)} }
+
+func Int64(key string, value int64) Field { return Field{key, value} }
+
+func Uint64(key string, value uint64) Field { return Field{key, value} }
+
+func Float64(key string, value float64) Field { return Field{key, value} }
+
+func Bool(key string, value bool) Field { return Field{key, value} }
+
+// Duration は"1.5s"のようにtime.Duration.String()の形式で出力される
+func Duration(key string, value time.Duration) Field { return Field{key, value} }
+
+// Time はRFC3339(ナノ秒まで)の形式で出力される
+func Time(key string, value time.Time) Field { return Field{key, value} }
+
+// Err はキーを"error"固定にしてerr.Error()を出力する。errがnilの場合はnull
+func Err(err error) Field { return Field{"error", err} }
+
+// Any は上にない型用。エンコーダーごとにjson.Marshalや%+vで出力される
+func Any(key string, value interface{}) Field { return Field{key, value} }
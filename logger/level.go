@@ -0,0 +1,62 @@
+package logger
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Level はログの重要度
+// 数字が大きいほど重要で、ロガーに設定した最低レベル未満の出力は捨てられる
+type Level int32
+
+const (
+	DebugLevel Level = iota
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+	FatalLevel
+
+	levelCount = int(FatalLevel) + 1
+)
+
+var levelNames = [levelCount]string{"DEBUG", "INFO", "WARN", "ERROR", "FATAL"}
+
+func (l Level) String() string {
+	if l < DebugLevel || l > FatalLevel {
+		return fmt.Sprintf("LEVEL(%d)", int32(l))
+	}
+	return levelNames[l]
+}
+
+// ParseLevel は"debug"や"WARN"のような文字列からLevelを得る
+// 大文字小文字は区別しない。"warning"も"warn"として扱う
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "DEBUG":
+		return DebugLevel, nil
+	case "INFO":
+		return InfoLevel, nil
+	case "WARN", "WARNING":
+		return WarnLevel, nil
+	case "ERROR":
+		return ErrorLevel, nil
+	case "FATAL":
+		return FatalLevel, nil
+	}
+	return InfoLevel, fmt.Errorf("logger: unknown level %q", s)
+}
+
+// MarshalText はjsonなどでLevelを文字列として扱うためのもの
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(strings.ToLower(l.String())), nil
+}
+
+// UnmarshalText はParseLevelと同じ規則で文字列を読み込む
+func (l *Level) UnmarshalText(text []byte) error {
+	lv, err := ParseLevel(string(text))
+	if err != nil {
+		return err
+	}
+	*l = lv
+	return nil
+}
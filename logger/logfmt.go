@@ -0,0 +1,193 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// LogfmtEncoder はkey=value形式(logfmt)の1行を出力する
+//
+//	time=2021-01-19T18:50:07+09:00 level=info msg="プレフィックスをつける" id=123 name=Graham
+//
+// スペースや"、=を含む値と空文字は""で囲んでエスケープする
+// 日本語はそのまま出力するが、全角スペースもスペースとして扱うので""で囲まれる
+type LogfmtEncoder struct {
+	// Flags はlog.LstdFlagsなどのlogパッケージのフラグ
+	Flags int
+}
+
+func NewLogfmtEncoder(flag int) *LogfmtEncoder {
+	return &LogfmtEncoder{Flags: flag}
+}
+
+func (enc *LogfmtEncoder) Encode(e *Entry) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if ts, ok := formatTime(e.Time, enc.Flags); ok {
+		buf.WriteString("time=")
+		appendLogfmtValue(buf, ts)
+		buf.WriteByte(' ')
+	}
+	buf.WriteString("level=")
+	buf.WriteString(levelKey(e.Level))
+	buf.WriteString(" msg=")
+	appendLogfmtValue(buf, e.Message)
+	if e.Caller != nil {
+		buf.WriteString(" caller=")
+		appendLogfmtValue(buf, e.Caller.String())
+		buf.WriteString(" func=")
+		appendLogfmtValue(buf, e.Caller.Function)
+	}
+	if e.Stack != "" {
+		buf.WriteString(" stack=")
+		appendLogfmtValue(buf, e.Stack)
+	}
+	for _, f := range e.Fields {
+		buf.WriteByte(' ')
+		appendLogfmtKey(buf, f.Key)
+		buf.WriteByte('=')
+		appendLogfmtValue(buf, logfmtString(f.Value))
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+func logfmtString(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case bool:
+		return strconv.FormatBool(val)
+	case int64:
+		return strconv.FormatInt(val, 10)
+	case uint64:
+		return strconv.FormatUint(val, 10)
+	case float64:
+		return strconv.FormatFloat(val, 'g', -1, 64)
+	case time.Duration:
+		return val.String()
+	case time.Time:
+		return val.Format(time.RFC3339Nano)
+	case error:
+		return val.Error()
+	}
+	return fmt.Sprintf("%+v", v)
+}
+
+// キーはクォートできないので使えない文字は_に置き換える
+func appendLogfmtKey(buf *bytes.Buffer, key string) {
+	if key == "" {
+		buf.WriteByte('_')
+		return
+	}
+	for _, r := range key {
+		if r == '=' || r == '"' || unicode.IsSpace(r) || !unicode.IsPrint(r) {
+			r = '_'
+		}
+		buf.WriteRune(r)
+	}
+}
+
+func appendLogfmtValue(buf *bytes.Buffer, s string) {
+	if needsQuote(s) {
+		buf.WriteString(strconv.Quote(s))
+		return
+	}
+	buf.WriteString(s)
+}
+
+func needsQuote(s string) bool {
+	if s == "" || !utf8.ValidString(s) {
+		return true
+	}
+	for _, r := range s {
+		if r == '=' || r == '"' || r == '\\' || unicode.IsSpace(r) || !unicode.IsPrint(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// DecodeLogfmt はLogfmtEncoderで出力した1行をkey/valueのmapに戻す
+// time, level, msgも他のフィールドと同じく文字列として入る
+// 値のないキー(key=もしくはkeyのみ)は空文字になる
+func DecodeLogfmt(line []byte) (map[string]string, error) {
+	pairs, err := decodeLogfmtPairs(line)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]string, len(pairs))
+	for _, p := range pairs {
+		m[p[0]] = p[1]
+	}
+	return m, nil
+}
+
+// 出現順を保ったまま[key, value]の組に分解する
+func decodeLogfmtPairs(line []byte) ([][2]string, error) {
+	var pairs [][2]string
+	s := strings.TrimRight(string(line), "\r\n")
+	for i := 0; i < len(s); {
+		if s[i] == ' ' || s[i] == '\t' {
+			i++
+			continue
+		}
+
+		start := i
+		for i < len(s) && s[i] != '=' && s[i] != ' ' && s[i] != '\t' {
+			if s[i] == '"' {
+				return nil, fmt.Errorf("logger: logfmt: unexpected quote in key at %d", i)
+			}
+			i++
+		}
+		key := s[start:i]
+		if i >= len(s) || s[i] != '=' {
+			pairs = append(pairs, [2]string{key, ""})
+			continue
+		}
+		i++ // =
+
+		if i < len(s) && s[i] == '"' {
+			end, err := quotedEnd(s, i)
+			if err != nil {
+				return nil, err
+			}
+			v, err := strconv.Unquote(s[i:end])
+			if err != nil {
+				return nil, fmt.Errorf("logger: logfmt: bad quoted value for %q: %v", key, err)
+			}
+			pairs = append(pairs, [2]string{key, v})
+			i = end
+			continue
+		}
+
+		start = i
+		for i < len(s) && s[i] != ' ' && s[i] != '\t' {
+			i++
+		}
+		pairs = append(pairs, [2]string{key, s[start:i]})
+	}
+	return pairs, nil
+}
+
+var errUnterminated = errors.New("logger: logfmt: unterminated quoted value")
+
+// s[i]の"に対応する閉じ"の次の位置を返す
+func quotedEnd(s string, i int) (int, error) {
+	for j := i + 1; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '"':
+			return j + 1, nil
+		}
+	}
+	return 0, errUnterminated
+}
@@ -0,0 +1,120 @@
+package logger
+
+import (
+	"log"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLogfmtEncoder(t *testing.T) {
+	tests := []struct {
+		name  string
+		flags int
+		entry Entry
+		want  string
+	}{
+		{
+			name:  "no time",
+			entry: Entry{Level: InfoLevel, Message: "spam"},
+			want:  `level=info msg=spam`,
+		},
+		{
+			name:  "time",
+			flags: log.LstdFlags | log.LUTC,
+			entry: Entry{Time: testTime, Level: WarnLevel, Message: "spam"},
+			want:  `time=2021-01-19T09:57:09Z level=warn msg=spam`,
+		},
+		{
+			name:  "quote",
+			entry: Entry{Level: InfoLevel, Message: "プレフィックスをつける", Fields: []Field{String("a", "x y"), String("b", `"q"`), String("c", "k=v"), String("d", ""), String("e", "全角　スペース")}},
+			want:  `level=info msg=プレフィックスをつける a="x y" b="\"q\"" c="k=v" d="" e="全角\u3000スペース"`,
+		},
+		{
+			name:  "values",
+			entry: Entry{Level: DebugLevel, Message: "spam", Fields: []Field{Int("id", 123), Bool("ok", false), Duration("d", time.Second), Err(nil), Any("m", map[string]int{"a": 1})}},
+			want:  `level=debug msg=spam id=123 ok=false d=1s error="" m=map[a:1]`,
+		},
+		{
+			name:  "bad keys",
+			entry: Entry{Level: InfoLevel, Message: "spam", Fields: []Field{String("a b", "1"), String("", "2"), String(`k="`, "3")}},
+			want:  `level=info msg=spam a_b=1 _=2 k__=3`,
+		},
+		{
+			name:  "control characters",
+			entry: Entry{Level: InfoLevel, Message: "a\nb\tc\x00"},
+			want:  `level=info msg="a\nb\tc\x00"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := NewLogfmtEncoder(tt.flags).Encode(&tt.entry)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(b); got != tt.want+"\n" {
+				t.Errorf("\n got %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeLogfmt(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"simple", "level=info msg=spam id=123\n", map[string]string{"level": "info", "msg": "spam", "id": "123"}, false},
+		{"quoted", `msg="x y" q="\"a\"" nl="a\nb"`, map[string]string{"msg": "x y", "q": `"a"`, "nl": "a\nb"}, false},
+		{"empty values", `a= b c=""`, map[string]string{"a": "", "b": "", "c": ""}, false},
+		{"extra spaces", "  a=1 \t b=2  \r\n", map[string]string{"a": "1", "b": "2"}, false},
+		{"japanese", `msg=日本語 e="全角　スペース"`, map[string]string{"msg": "日本語", "e": "全角　スペース"}, false},
+		{"empty line", "", map[string]string{}, false},
+		{"unterminated", `msg="spam`, nil, true},
+		{"quote in key", `"a"=1`, nil, true},
+		{"bad escape", `msg="\q"`, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeLogfmt([]byte(tt.line))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// エンコードしたものをデコードすると元の値に戻る
+func TestLogfmtRoundTrip(t *testing.T) {
+	fields := []Field{
+		String("plain", "abc"),
+		String("space", "a b"),
+		String("quote", `say "hi"`),
+		String("backslash", `C:\tmp`),
+		String("newline", "a\nb"),
+		String("empty", ""),
+		String("unicode", "日本語　全角"),
+		String("equals", "a=b"),
+	}
+	b, err := NewLogfmtEncoder(0).Encode(&Entry{Level: InfoLevel, Message: "round trip", Fields: fields})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := DecodeLogfmt(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["msg"] != "round trip" || got["level"] != "info" {
+		t.Errorf("got %v", got)
+	}
+	for _, f := range fields {
+		if got[f.Key] != f.Value {
+			t.Errorf("%s = %q, want %q", f.Key, got[f.Key], f.Value)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+/*
+leveled logger
+
+log.goのhandlers()でやっていた
+「DEBUGのときだけ出力先を変える」処理をパッケージにしたもの
+
+document:
+  - https://pkg.go.dev/log
+*/
+package logger
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync/atomic"
+)
+
+// Logger はレベルごとに*log.Loggerを持つロガー
+// 設定した最低レベル未満の出力はNoneWriterに流したときと同じく捨てられる。
+// 出力先はレベルごとに変えられるので、例えばERROR以上だけ別ファイルに書き出すといったことができる
+type Logger struct {
+	level   int32
+	loggers [levelCount]*log.Logger
+}
+
+// New はすべてのレベルの出力先をoutにしたLoggerを作る
+// prefixとflagはlog.Newと同じだが、prefixの後ろに"[DEBUG] "のようなレベル表記がつく
+// 最低レベルはInfoLevel
+func New(out io.Writer, prefix string, flag int) *Logger {
+	l := &Logger{level: int32(InfoLevel)}
+	for lv := DebugLevel; lv <= FatalLevel; lv++ {
+		l.loggers[lv] = log.New(out, prefix+levelTag(lv), flag)
+	}
+	return l
+}
+
+func levelTag(lv Level) string {
+	return "[" + lv.String() + "] "
+}
+
+// Level は現在の最低レベルを返す
+func (l *Logger) Level() Level {
+	return Level(atomic.LoadInt32(&l.level))
+}
+
+// SetLevel は最低レベルを変える。動作中に別のgoroutineから呼び出しても問題ない
+func (l *Logger) SetLevel(lv Level) {
+	atomic.StoreInt32(&l.level, int32(lv))
+}
+
+// Enabled はlvの出力が有効かどうかを返す
+// 出力内容の組み立てが重い場合に事前に確認するために使う
+func (l *Logger) Enabled(lv Level) bool {
+	return lv >= l.Level()
+}
+
+// SetOutput はすべてのレベルの出力先を変える
+func (l *Logger) SetOutput(w io.Writer) {
+	for _, lg := range l.loggers {
+		lg.SetOutput(w)
+	}
+}
+
+// SetLevelOutput はlvの出力先だけを変える
+func (l *Logger) SetLevelOutput(lv Level, w io.Writer) {
+	l.loggers[lv].SetOutput(w)
+}
+
+// SetFlags はすべてのレベルのフラグを変える
+func (l *Logger) SetFlags(flag int) {
+	for _, lg := range l.loggers {
+		lg.SetFlags(flag)
+	}
+}
+
+// SetPrefix はすべてのレベルのプレフィックスを変える
+// レベル表記はprefixの後ろにつけ直される
+func (l *Logger) SetPrefix(prefix string) {
+	for lv, lg := range l.loggers {
+		lg.SetPrefix(prefix + levelTag(Level(lv)))
+	}
+}
+
+// Writer はlvの出力先を返す
+func (l *Logger) Writer(lv Level) io.Writer {
+	return l.loggers[lv].Writer()
+}
+
+// Debug から Fatal まではlog.Printlnと、末尾にfがつくものはlog.Printfと同じ書式
+
+func (l *Logger) Debug(v ...interface{}) { l.output(DebugLevel, fmt.Sprintln(v...)) }
+func (l *Logger) Info(v ...interface{})  { l.output(InfoLevel, fmt.Sprintln(v...)) }
+func (l *Logger) Warn(v ...interface{})  { l.output(WarnLevel, fmt.Sprintln(v...)) }
+func (l *Logger) Error(v ...interface{}) { l.output(ErrorLevel, fmt.Sprintln(v...)) }
+
+func (l *Logger) Debugf(format string, v ...interface{}) {
+	l.output(DebugLevel, fmt.Sprintf(format, v...))
+}
+func (l *Logger) Infof(format string, v ...interface{}) {
+	l.output(InfoLevel, fmt.Sprintf(format, v...))
+}
+func (l *Logger) Warnf(format string, v ...interface{}) {
+	l.output(WarnLevel, fmt.Sprintf(format, v...))
+}
+func (l *Logger) Errorf(format string, v ...interface{}) {
+	l.output(ErrorLevel, fmt.Sprintf(format, v...))
+}
+
+// Fatal は出力後にstatus code 1で終了する。最低レベルに関係なく終了はする
+func (l *Logger) Fatal(v ...interface{}) {
+	l.output(FatalLevel, fmt.Sprintln(v...))
+	os.Exit(1)
+}
+
+func (l *Logger) Fatalf(format string, v ...interface{}) {
+	l.output(FatalLevel, fmt.Sprintf(format, v...))
+	os.Exit(1)
+}
+
+// calldepthはDebug()等の呼び出し元を指すようにする (Lshortfile用)
+func (l *Logger) output(lv Level, s string) {
+	if !l.Enabled(lv) {
+		return
+	}
+	l.loggers[lv].Output(3, s)
+}
@@ -0,0 +1,205 @@
+/*
+logのテスト用ヘルパー
+
+net/http/httptestのように、テストからだけ使うことを想定している
+
+document:
+  - https://pkg.go.dev/testing
+*/
+package logtest
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/nc30/golang_examples/logger"
+)
+
+// Recorder はテスト中に出力されたログを溜めておく
+// 出力された内容はt.Logにも流すので、テストが失敗したときか-vのときだけ表示される
+// テストが終わった後(止め忘れたgoroutineからなど)に出力されたものは溜めるだけでt.Logには流さない
+//
+// io.Writerとしてもlogger.Sinkとしても使える
+type Recorder struct {
+	t   testing.TB
+	enc logger.Encoder
+
+	mu      sync.Mutex
+	lines   []string
+	entries []*logger.Entry
+	// テストが終わった後にt.Logを呼ぶとpanicするので、Cleanupで立てる
+	finished bool
+}
+
+// NewRecorder はtに紐付いたRecorderを作る
+func NewRecorder(t testing.TB) *Recorder {
+	r := &Recorder{t: t, enc: logger.NewLogfmtEncoder(0)}
+	t.Cleanup(func() {
+		r.mu.Lock()
+		r.finished = true
+		r.mu.Unlock()
+	})
+	return r
+}
+
+// テストが終わっていなければt.Logに流す
+// Cleanupと同時に呼ばれても終わった後に流さないよう、ロックしたまま呼ぶ
+func (r *Recorder) log(line string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.finished {
+		r.t.Log(line)
+	}
+}
+
+// CaptureStd は標準のlogパッケージの出力先をRecorderにする
+// テストが終わると元の出力先に戻すので、並列(t.Parallel)のテストでは使わないこと
+func CaptureStd(t testing.TB) *Recorder {
+	r := NewRecorder(t)
+	prev := log.Writer()
+	log.SetOutput(r)
+	t.Cleanup(func() { log.SetOutput(prev) })
+	return r
+}
+
+// NewLogger はRecorderに書き出すStructuredLoggerを作る。最低レベルはDebugLevel
+func NewLogger(t testing.TB) (*logger.StructuredLogger, *Recorder) {
+	r := NewRecorder(t)
+	l := logger.NewStructured(r)
+	l.SetLevel(logger.DebugLevel)
+	return l, r
+}
+
+// Write は1回のWriteを1行として溜める
+func (r *Recorder) Write(p []byte) (int, error) {
+	line := strings.TrimRight(string(p), "\n")
+	r.mu.Lock()
+	r.lines = append(r.lines, line)
+	r.mu.Unlock()
+
+	r.log(line)
+	return len(p), nil
+}
+
+// WriteEntry はEntryをそのまま溜め、logfmtにしてt.Logに流す
+func (r *Recorder) WriteEntry(e *logger.Entry) error {
+	r.mu.Lock()
+	r.entries = append(r.entries, e)
+	r.mu.Unlock()
+
+	b, err := r.enc.Encode(e)
+	if err != nil {
+		return err
+	}
+	r.log(strings.TrimRight(string(b), "\n"))
+	return nil
+}
+
+// Lines はWriteされた行を返す
+func (r *Recorder) Lines() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.lines...)
+}
+
+// Entries はWriteEntryされたEntryを返す
+func (r *Recorder) Entries() []*logger.Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*logger.Entry(nil), r.entries...)
+}
+
+// Reset は溜めた内容を捨てる
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lines = nil
+	r.entries = nil
+}
+
+// Contains はsubstrを含む行かメッセージがあるかを返す
+func (r *Recorder) Contains(substr string) bool {
+	for _, line := range r.Lines() {
+		if strings.Contains(line, substr) {
+			return true
+		}
+	}
+	for _, e := range r.Entries() {
+		if strings.Contains(e.Message, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Find はlvで、kvに指定したフィールドをすべて持つEntryを返す
+// kvは "url", "https://httpbin.org/get", "status", 200 のようにキーと値を交互に並べる
+// キーに"msg"を指定した場合はメッセージと比較する
+// 値はfmt.Sprintで文字列にして比較するので、Int()で作ったフィールドに200を渡しても一致する
+func (r *Recorder) Find(lv logger.Level, kv ...interface{}) []*logger.Entry {
+	if len(kv)%2 != 0 {
+		r.t.Helper()
+		r.t.Fatalf("logtest: odd number of key/value arguments: %v", kv)
+	}
+
+	var found []*logger.Entry
+	for _, e := range r.Entries() {
+		if e.Level == lv && matchFields(e, kv) {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+func matchFields(e *logger.Entry, kv []interface{}) bool {
+	for i := 0; i < len(kv); i += 2 {
+		key := fmt.Sprint(kv[i])
+		want := fmt.Sprint(kv[i+1])
+		if key == "msg" {
+			if e.Message != want {
+				return false
+			}
+			continue
+		}
+		ok := false
+		for _, f := range e.Fields {
+			if f.Key == key && fmt.Sprint(f.Value) == want {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// AssertContains はsubstrを含む行が無ければテストを失敗にする
+func (r *Recorder) AssertContains(substr string) {
+	r.t.Helper()
+	if !r.Contains(substr) {
+		r.t.Errorf("logtest: no log line contains %q", substr)
+	}
+}
+
+// AssertEntry はFindで1件も見つからなければテストを失敗にする
+//
+//	rec.AssertEntry(logger.ErrorLevel, "url", "https://httpbin.org/status/500")
+func (r *Recorder) AssertEntry(lv logger.Level, kv ...interface{}) {
+	r.t.Helper()
+	if len(r.Find(lv, kv...)) == 0 {
+		r.t.Errorf("logtest: no %s entry with %v", lv, kv)
+	}
+}
+
+// AssertNoEntry はFindで見つかった場合にテストを失敗にする
+func (r *Recorder) AssertNoEntry(lv logger.Level, kv ...interface{}) {
+	r.t.Helper()
+	if found := r.Find(lv, kv...); len(found) > 0 {
+		r.t.Errorf("logtest: found %d unexpected %s entries with %v", len(found), lv, kv)
+	}
+}
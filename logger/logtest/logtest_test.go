@@ -0,0 +1,122 @@
+package logtest
+
+import (
+	"fmt"
+	"log"
+	"testing"
+
+	"github.com/nc30/golang_examples/logger"
+)
+
+// fakeTB はAssert系が失敗を報告したかどうかと、t.Logに流された行を記録する
+type fakeTB struct {
+	testing.TB
+	errors  []string
+	fatal   bool
+	logs    []string
+	cleanup []func()
+}
+
+func (f *fakeTB) Helper()                 {}
+func (f *fakeTB) Cleanup(fn func())       { f.cleanup = append(f.cleanup, fn) }
+func (f *fakeTB) Log(args ...interface{}) { f.logs = append(f.logs, fmt.Sprint(args...)) }
+func (f *fakeTB) Errorf(format string, args ...interface{}) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+func (f *fakeTB) Fatalf(format string, args ...interface{}) {
+	f.Errorf(format, args...)
+	f.fatal = true
+}
+
+func TestRecorderEntries(t *testing.T) {
+	l, rec := NewLogger(t)
+	l.Debug("start")
+	l.Error("request failed", logger.String("url", "https://httpbin.org/status/500"), logger.Int("status", 500))
+
+	rec.AssertContains("request failed")
+	rec.AssertEntry(logger.ErrorLevel, "url", "https://httpbin.org/status/500", "status", 500)
+	rec.AssertEntry(logger.DebugLevel, "msg", "start")
+	rec.AssertNoEntry(logger.ErrorLevel, "status", 200)
+	rec.AssertNoEntry(logger.InfoLevel)
+
+	if n := len(rec.Entries()); n != 2 {
+		t.Errorf("Entries() = %d, want 2", n)
+	}
+	rec.Reset()
+	if rec.Contains("request failed") || len(rec.Entries()) != 0 {
+		t.Error("Reset did not clear entries")
+	}
+}
+
+func TestCaptureStd(t *testing.T) {
+	prev := log.Writer()
+	t.Run("capture", func(t *testing.T) {
+		rec := CaptureStd(t)
+		log.Printf("hello %s", "world")
+		rec.AssertContains("hello world")
+		if lines := rec.Lines(); len(lines) != 1 {
+			t.Errorf("Lines() = %q", lines)
+		}
+	})
+	if log.Writer() != prev {
+		t.Error("log output was not restored")
+	}
+}
+
+func TestAssertFailures(t *testing.T) {
+	tests := []struct {
+		name   string
+		assert func(rec *Recorder)
+		fails  bool
+	}{
+		{"contains ok", func(rec *Recorder) { rec.AssertContains("spam") }, false},
+		{"contains missing", func(rec *Recorder) { rec.AssertContains("eggs") }, true},
+		{"entry ok", func(rec *Recorder) { rec.AssertEntry(logger.InfoLevel, "id", 1) }, false},
+		{"entry wrong level", func(rec *Recorder) { rec.AssertEntry(logger.WarnLevel, "id", 1) }, true},
+		{"entry wrong value", func(rec *Recorder) { rec.AssertEntry(logger.InfoLevel, "id", 2) }, true},
+		{"no entry ok", func(rec *Recorder) { rec.AssertNoEntry(logger.InfoLevel, "id", 2) }, false},
+		{"no entry found", func(rec *Recorder) { rec.AssertNoEntry(logger.InfoLevel, "msg", "spam") }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tb := &fakeTB{}
+			rec := NewRecorder(tb)
+			logger.NewStructured(rec).Info("spam", logger.Int("id", 1))
+			tt.assert(rec)
+			if failed := len(tb.errors) > 0; failed != tt.fails {
+				t.Errorf("failed = %v, want %v: %v", failed, tt.fails, tb.errors)
+			}
+		})
+	}
+}
+
+func TestFindOddArguments(t *testing.T) {
+	tb := &fakeTB{}
+	NewRecorder(tb).Find(logger.InfoLevel, "id")
+	if !tb.fatal {
+		t.Error("odd key/value arguments should be fatal")
+	}
+}
+
+// テストが終わった後に書き込まれてもt.Logは呼ばない
+func TestRecorderAfterTestFinished(t *testing.T) {
+	tb := &fakeTB{}
+	l, rec := NewLogger(tb)
+	std := log.New(rec, "", 0)
+
+	l.Info("during")
+	std.Print("during")
+	for _, fn := range tb.cleanup {
+		fn()
+	}
+	l.Info("after")
+	std.Print("after")
+
+	if len(tb.logs) != 2 {
+		t.Errorf("t.Log was called with %q, want 2 lines", tb.logs)
+	}
+	// 溜めるのは続ける
+	if len(rec.Entries()) != 2 || len(rec.Lines()) != 2 {
+		t.Errorf("Entries() = %d, Lines() = %d", len(rec.Entries()), len(rec.Lines()))
+	}
+}
@@ -0,0 +1,141 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ParseEntry はJSONEncoderかLogfmtEncoderで出力した1行をEntryに戻す
+// 先頭が{ならjson、それ以外ならlogfmtとして読む
+// time, level, msg, caller, func, stackはEntryの各項目に、それ以外はFieldsに出現順で入る
+// Callerは出力時に短くしたファイル名しか残っていないのでFileにはそれが入る (Lineは0)
+func ParseEntry(line []byte) (*Entry, error) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return nil, errors.New("logger: empty line")
+	}
+	if line[0] == '{' {
+		return parseJSONEntry(line)
+	}
+	return parseLogfmtEntry(line)
+}
+
+func parseLogfmtEntry(line []byte) (*Entry, error) {
+	pairs, err := decodeLogfmtPairs(line)
+	if err != nil {
+		return nil, err
+	}
+	e := &Entry{Level: InfoLevel}
+	for _, p := range pairs {
+		if err := e.setKey(p[0], p[1]); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+func parseJSONEntry(line []byte) (*Entry, error) {
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, errors.New("logger: json entry is not an object")
+	}
+
+	e := &Entry{Level: InfoLevel}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := tok.(string)
+
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			return nil, err
+		}
+		if num, ok := v.(json.Number); ok {
+			if i, err := num.Int64(); err == nil {
+				v = i
+			} else if f, err := num.Float64(); err == nil {
+				v = f
+			}
+		}
+		if err := e.setKey(key, v); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+var parseTimeLayouts = []string{time.RFC3339Nano, timeLayoutMicro}
+
+func (e *Entry) setKey(key string, v interface{}) error {
+	s, isString := v.(string)
+	switch key {
+	case "time":
+		if !isString {
+			break
+		}
+		for _, layout := range parseTimeLayouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				e.Time = t
+				return nil
+			}
+		}
+		return fmt.Errorf("logger: bad time %q", s)
+	case "level":
+		if !isString {
+			break
+		}
+		lv, err := ParseLevel(s)
+		if err != nil {
+			return err
+		}
+		e.Level = lv
+		return nil
+	case "msg":
+		if isString {
+			e.Message = s
+			return nil
+		}
+	case "caller":
+		if isString {
+			e.caller().File = s
+			return nil
+		}
+	case "func":
+		if isString {
+			e.caller().Function = s
+			return nil
+		}
+	case "stack":
+		if isString {
+			e.Stack = s
+			return nil
+		}
+	}
+	e.Fields = append(e.Fields, Field{Key: key, Value: v})
+	return nil
+}
+
+func (e *Entry) caller() *Caller {
+	if e.Caller == nil {
+		e.Caller = &Caller{}
+	}
+	return e.Caller
+}
+
+// FieldString はkeyのFieldの値をlogfmtで出力するときと同じ文字列にして返す
+// 同じキーが複数ある場合は最初のもの
+func (e *Entry) FieldString(key string) (string, bool) {
+	for _, f := range e.Fields {
+		if f.Key == key {
+			return logfmtString(f.Value), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,226 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// RedactConfig はRedactorで隠す対象
+type RedactConfig struct {
+	// Headers は"Authorization: [Bearer xxx]"のようなヘッダの出力で値を隠すヘッダ名
+	// jsonのキーとしても扱う
+	Headers []string
+	// JSONKeys は値を隠すjsonのキー。{"token": "xxx"} のtokenなど
+	JSONKeys []string
+	// Patterns はマッチした部分を隠す正規表現
+	Patterns []string
+	// Mask は隠した値の代わりに出力する文字列。空なら"***"
+	Mask string
+}
+
+// Redactor はログに出してはいけない値を隠す
+// http/clientのpost()のようにヘッダやレスポンスをそのままログに出すと
+// Authorizationヘッダやトークンまで出力されてしまうので、出力先の手前で置き換える
+// 大文字小文字は区別しない
+type Redactor struct {
+	mask     string
+	keys     map[string]bool
+	header   *regexp.Regexp
+	headerV  *regexp.Regexp
+	json     *regexp.Regexp
+	jsonEsc  *regexp.Regexp
+	patterns []*regexp.Regexp
+}
+
+// NewRedactor はconfからRedactorを作る。Patternsが正規表現として正しくない場合はエラーを返す
+func NewRedactor(conf RedactConfig) (*Redactor, error) {
+	r := &Redactor{mask: conf.Mask, keys: map[string]bool{}}
+	if r.mask == "" {
+		r.mask = "***"
+	}
+
+	var headers, keys []string
+	for _, h := range conf.Headers {
+		headers = append(headers, regexp.QuoteMeta(h))
+		keys = append(keys, regexp.QuoteMeta(h))
+		r.keys[strings.ToLower(h)] = true
+	}
+	for _, k := range conf.JSONKeys {
+		keys = append(keys, regexp.QuoteMeta(k))
+		r.keys[strings.ToLower(k)] = true
+	}
+
+	if len(headers) > 0 {
+		names := strings.Join(headers, "|")
+		// fmt.Printf("%+v", header)の形式
+		// map[Accept:[*/*] Authorization:[Bearer xxx]] -> map[Accept:[*/*] Authorization:[***]]
+		r.headerV = regexp.MustCompile(`(?i)\b(` + names + `)(\s*:\s*)\[[^\]\r\n]*\]`)
+		// Authorization: Bearer xxx -> Authorization: ***
+		// jsonの文字列の中にあっても壊さないように"と\の手前で止める
+		r.header = regexp.MustCompile(`(?i)\b(` + names + `)(\s*:[ \t]*)[^\[\s"\\][^\r\n"\\]*`)
+	}
+	if len(keys) > 0 {
+		names := strings.Join(keys, "|")
+		// "token": "xxx" -> "token": "***"
+		r.json = regexp.MustCompile(`(?i)("(?:` + names + `)"\s*:\s*)("(?:[^"\\]|\\.)*"|[^,}\]\s]+)`)
+		// jsonの文字列の中にエスケープされて入っているjson
+		// "body":"{\"token\":\"xxx\"}" -> "body":"{\"token\":\"***\"}"
+		r.jsonEsc = regexp.MustCompile(`(?i)(\\"(?:` + names + `)\\"\s*:\s*)(\\"(?:\\\\\\"|\\\\\\\\|\\[^"\\]|[^"\\])*\\"|[^,}\]\s\\]+)`)
+	}
+	for _, p := range conf.Patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, err
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// Redact はsの中の隠す対象をMaskに置き換える
+func (r *Redactor) Redact(s string) string {
+	if r.header != nil {
+		s = r.headerV.ReplaceAllString(s, "${1}${2}["+r.escapedMask()+"]")
+		s = r.header.ReplaceAllString(s, "${1}${2}"+r.escapedMask())
+	}
+	if r.json != nil {
+		s = r.json.ReplaceAllString(s, `${1}"`+r.escapedMask()+`"`)
+		s = r.jsonEsc.ReplaceAllString(s, `${1}\"`+r.escapedMask()+`\"`)
+	}
+	for _, re := range r.patterns {
+		s = re.ReplaceAllLiteralString(s, r.mask)
+	}
+	return s
+}
+
+// ReplaceAllStringの置換文字列では$が特別な意味を持つのでエスケープする
+func (r *Redactor) escapedMask() string {
+	return strings.Replace(r.mask, "$", "$$", -1)
+}
+
+func (r *Redactor) isSecretKey(key string) bool {
+	return r.keys[strings.ToLower(key)]
+}
+
+// Writer はwに書き込む前にRedactするio.Writerを返す
+// log.SetOutput(r.Writer(os.Stdout))のように使う
+func (r *Redactor) Writer(w io.Writer) io.Writer {
+	return &redactingWriter{r: r, w: w}
+}
+
+type redactingWriter struct {
+	r *Redactor
+	w io.Writer
+}
+
+// 置き換えで長さが変わるので、書き込めた場合はlen(p)を返す
+func (rw *redactingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(rw.w, rw.r.Redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Sink はsinkに渡す前にEntryのメッセージとFieldをRedactするSinkを返す
+// キーがHeadersかJSONKeysに一致するFieldは値ごと隠し、
+// それ以外の文字列・error・http.Headerの値は中身をRedactする
+func (r *Redactor) Sink(sink Sink) Sink {
+	return &redactingSink{r: r, sink: sink}
+}
+
+type redactingSink struct {
+	r    *Redactor
+	sink Sink
+}
+
+func (rs *redactingSink) WriteEntry(e *Entry) error {
+	c := *e
+	c.Message = rs.r.Redact(e.Message)
+	c.Fields = make([]Field, len(e.Fields))
+	for i, f := range e.Fields {
+		c.Fields[i] = rs.r.redactField(f)
+	}
+	return rs.sink.WriteEntry(&c)
+}
+
+func (r *Redactor) redactField(f Field) Field {
+	if r.isSecretKey(f.Key) {
+		return String(f.Key, r.mask)
+	}
+	switch v := f.Value.(type) {
+	case nil, bool, int64, uint64, float64, time.Duration, time.Time:
+		return f
+	case string:
+		return String(f.Key, r.Redact(v))
+	case error:
+		return String(f.Key, r.Redact(v.Error()))
+	case http.Header:
+		h := make(http.Header, len(v))
+		for name, values := range v {
+			if r.isSecretKey(name) {
+				h[name] = []string{r.mask}
+				continue
+			}
+			h[name] = make([]string, len(values))
+			for i, s := range values {
+				h[name][i] = r.Redact(s)
+			}
+		}
+		return Any(f.Key, h)
+	}
+
+	// map・スライス・構造体などはjsonにしてから中身を見る
+	// 何も隠さなかった場合は元の値のまま出力する
+	b, err := json.Marshal(f.Value)
+	if err != nil {
+		// Marshalできないものはエンコーダーと同じく%+vの文字列にしてRedactする
+		return String(f.Key, r.Redact(fmt.Sprintf("%+v", f.Value)))
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return String(f.Key, r.Redact(string(b)))
+	}
+	if v, changed := r.redactValue(v); changed {
+		return Any(f.Key, v)
+	}
+	return f
+}
+
+// redactValue はjson.Unmarshalしたvを再帰的にたどって隠す対象を置き換える
+func (r *Redactor) redactValue(v interface{}) (interface{}, bool) {
+	switch val := v.(type) {
+	case string:
+		s := r.Redact(val)
+		return s, s != val
+	case []interface{}:
+		changed := false
+		for i, e := range val {
+			var c bool
+			val[i], c = r.redactValue(e)
+			changed = changed || c
+		}
+		return val, changed
+	case map[string]interface{}:
+		changed := false
+		for k, e := range val {
+			if r.isSecretKey(k) {
+				val[k] = r.mask
+				changed = true
+				continue
+			}
+			var c bool
+			val[k], c = r.redactValue(e)
+			changed = changed || c
+		}
+		return val, changed
+	}
+	return v, false
+}
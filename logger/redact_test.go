@@ -0,0 +1,107 @@
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func newTestRedactor(t *testing.T) *Redactor {
+	t.Helper()
+	r, err := NewRedactor(RedactConfig{
+		Headers:  []string{"Authorization"},
+		JSONKeys: []string{"token", "password"},
+		Patterns: []string{`\d{4}-\d{4}-\d{4}-\d{4}`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestRedact(t *testing.T) {
+	r := newTestRedactor(t)
+	h := http.Header{
+		"Accept":        {"*/*"},
+		"Authorization": {"Bearer abc"},
+		"X-Request-Id":  {"42"},
+	}
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"header line", "Authorization: Bearer abc\r\nAccept: */*", "Authorization: ***\r\nAccept: */*"},
+		{"header %+v", fmt.Sprintf("%+v", h), "map[Accept:[*/*] Authorization:[***] X-Request-Id:[42]]"},
+		{"json", `{"token":"abc","id":1}`, `{"token":"***","id":1}`},
+		{"json number", `{"password": 1234, "id":1}`, `{"password": "***", "id":1}`},
+		{"escaped json", `{"body":"{\"token\":\"abc\",\"id\":1}"}`, `{"body":"{\"token\":\"***\",\"id\":1}"}`},
+		{"escaped quote in value", `{"body":"{\"token\":\"a\\\"b\"}"}`, `{"body":"{\"token\":\"***\"}"}`},
+		{"pattern", "card 1234-5678-9012-3456 ok", "card *** ok"},
+		{"case insensitive", `{"TOKEN":"abc"}`, `{"TOKEN":"***"}`},
+		{"untouched", `{"name":"abc"}`, `{"name":"abc"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.Redact(tt.in); got != tt.want {
+				t.Errorf("Redact(%q)\n got %q\nwant %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactorWriterEscapedBody(t *testing.T) {
+	r := newTestRedactor(t)
+	buf := &bytes.Buffer{}
+	l := NewStructured(NewWriterSink(r.Writer(buf), NewJSONEncoder(0)))
+
+	l.Info("post", String("body", `{"token":"abc"}`), String("auth", "Authorization: Bearer abc"))
+
+	got := buf.String()
+	if strings.Contains(got, "abc") {
+		t.Errorf("secret leaked: %s", got)
+	}
+	want := `{"level":"info","msg":"post","body":"{\"token\":\"***\"}","auth":"Authorization: ***"}` + "\n"
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestRedactorSinkStructuredValues(t *testing.T) {
+	r := newTestRedactor(t)
+	type login struct {
+		User     string `json:"user"`
+		Password string `json:"password"`
+	}
+
+	tests := []struct {
+		name  string
+		field Field
+		want  string
+	}{
+		{"map", Any("body", map[string]string{"token": "s3cr3t"}), `"body":{"token":"***"}`},
+		{"nested", Any("body", map[string]interface{}{"auth": map[string]string{"token": "s3cr3t"}}), `"body":{"auth":{"token":"***"}}`},
+		{"slice", Any("body", []string{"1234-5678-9012-3456"}), `"body":["***"]`},
+		{"struct", Any("body", login{"graham", "s3cr3t"}), `"body":{"password":"***","user":"graham"}`},
+		{"header", Any("h", http.Header{"Authorization": {"Bearer s3cr3t"}}), `"h":{"Authorization":["***"]}`},
+		{"secret key", String("token", "s3cr3t"), `"token":"***"`},
+		{"untouched", Any("body", map[string]int{"id": 1}), `"body":{"id":1}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			l := NewStructured(r.Sink(NewWriterSink(buf, NewJSONEncoder(0))))
+			l.Info("m", tt.field)
+			got := buf.String()
+			if strings.Contains(got, "s3cr3t") {
+				t.Errorf("secret leaked: %s", got)
+			}
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("got %s, want it to contain %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// Reopener は開き直しができる出力先。RotatingWriterが実装している
+type Reopener interface {
+	Reopen() error
+}
+
+// ReopenOnSignal はsigsを受け取るたびにrをReopenする。sigsを省略した場合はSIGHUP
+// logrotateのpostrotateで kill -HUP する運用向け
+// Reopenに失敗した場合は標準エラー出力に書き出す (rには書けないかもしれないので)
+// 戻り値のstopを呼ぶとシグナルの受け取りをやめる。何度呼んでもいい
+func ReopenOnSignal(r Reopener, sigs ...os.Signal) (stop func()) {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{syscall.SIGHUP}
+	}
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sigs...)
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ch:
+				if err := r.Reopen(); err != nil {
+					fmt.Fprintf(os.Stderr, "logger: reopen: %v\n", err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package logger
+
+import (
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// reopenNotifier はReopenが終わったことをテストに知らせる
+type reopenNotifier struct {
+	*RotatingWriter
+	reopened chan error
+}
+
+func (r *reopenNotifier) Reopen() error {
+	err := r.RotatingWriter.Reopen()
+	r.reopened <- err
+	return err
+}
+
+func TestReopenOnSignal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("SIGHUP is not supported on windows")
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	w, err := NewRotatingWriter(path, RotateConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	r := &reopenNotifier{w, make(chan error, 1)}
+	stop := ReopenOnSignal(r)
+	defer stop()
+
+	w.Write([]byte("before\n"))
+	// logrotateがmvした後にkill -HUPするのと同じことをする
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	p, _ := os.FindProcess(os.Getpid())
+	if err := p.Signal(syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-r.reopened:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("not reopened")
+	}
+	w.Write([]byte("after\n"))
+
+	if got := readFile(t, path+".1"); got != "before\n" {
+		t.Errorf("rotated = %q", got)
+	}
+	if got := readFile(t, path); got != "after\n" {
+		t.Errorf("current = %q", got)
+	}
+}
+
+func TestReopenOnSignalStop(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("SIGHUP is not supported on windows")
+	}
+
+	w, err := NewRotatingWriter(filepath.Join(t.TempDir(), "app.log"), RotateConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	r := &reopenNotifier{w, make(chan error, 1)}
+
+	// stopした後のSIGHUPでプロセスが終了しないように別に受け取っておく
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	defer signal.Stop(ch)
+
+	stop := ReopenOnSignal(r)
+	stop()
+	stop()
+
+	p, _ := os.FindProcess(os.Getpid())
+	p.Signal(syscall.SIGHUP)
+	<-ch
+	select {
+	case <-r.reopened:
+		t.Error("reopened after stop")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,154 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync"
+)
+
+// RingBuffer は直近n行だけをメモリに残すio.Writer
+// log.goのNoneWriterのようにDEBUG出力を捨てるのではなく、ここに書いておいて
+// 何か起こったときだけ中身を見られるようにする
+//
+//	ring := logger.NewRingBuffer(1000)
+//	l.SetLevelOutput(logger.DebugLevel, ring)
+//	mux.Handle("/debug/log", ring)
+//
+// 1回のWriteを1行として扱うので、log.Loggerかwriter sinkの出力先にする
+type RingBuffer struct {
+	mu    sync.Mutex
+	lines [][]byte
+	next  int
+	full  bool
+}
+
+// NewRingBuffer はn行分のRingBufferを作る
+func NewRingBuffer(n int) *RingBuffer {
+	if n <= 0 {
+		n = 1
+	}
+	return &RingBuffer{lines: make([][]byte, n)}
+}
+
+func (r *RingBuffer) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// 一周した後は古い行のバッファを使い回すので、行の長さが揃っていればアロケーションは起きない
+	r.lines[r.next] = append(r.lines[r.next][:0], p...)
+	r.next++
+	if r.next == len(r.lines) {
+		r.next = 0
+		r.full = true
+	}
+	return len(p), nil
+}
+
+// Len は今残っている行数を返す
+func (r *RingBuffer) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.full {
+		return len(r.lines)
+	}
+	return r.next
+}
+
+// Lines は古い順に行を返す。末尾の改行は取り除く
+func (r *RingBuffer) Lines() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var lines []string
+	r.each(func(b []byte) {
+		lines = append(lines, strings.TrimRight(string(b), "\n"))
+	})
+	return lines
+}
+
+func (r *RingBuffer) each(f func([]byte)) {
+	if r.full {
+		for _, b := range r.lines[r.next:] {
+			f(b)
+		}
+	}
+	for _, b := range r.lines[:r.next] {
+		f(b)
+	}
+}
+
+// WriteTo は古い順にwへ書き出す。書き出した後も中身は残る
+func (r *RingBuffer) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var total int64
+	var err error
+	r.each(func(b []byte) {
+		if err != nil {
+			return
+		}
+		var n int
+		n, err = w.Write(b)
+		total += int64(n)
+	})
+	return total, err
+}
+
+// Reset は中身を空にする
+func (r *RingBuffer) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.lines {
+		r.lines[i] = r.lines[i][:0]
+	}
+	r.next = 0
+	r.full = false
+}
+
+// ServeHTTP は中身をtext/plainで返す
+// http/serverのmuxに mux.Handle("/debug/log", ring) のようにそのまま登録できる
+func (r *RingBuffer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	r.WriteTo(w)
+}
+
+// Recover はpanicを拾ってpanicの内容、スタック、それまでのログをwに書き出す
+// recover()はdeferで直接呼ばれた関数の中でしか効かないので、必ずこのまま渡す
+//
+//	defer ring.Recover(os.Stderr)
+func (r *RingBuffer) Recover(w io.Writer) {
+	if e := recover(); e != nil {
+		r.dumpPanic(w, e)
+	}
+}
+
+func (r *RingBuffer) dumpPanic(w io.Writer, e interface{}) {
+	fmt.Fprintf(w, "panic: %v\n\n%s\n--- last %d log lines ---\n", e, debug.Stack(), r.Len())
+	r.WriteTo(w)
+}
+
+// RecoverHandler はnextの中で起きたpanicを拾ってwにダンプし、500を返すhttp.Handlerを作る
+// http/serverの/panicのように、コネクションをぶつ切りにせず原因も追えるようにする
+func (r *RingBuffer) RecoverHandler(w io.Writer, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		defer func() {
+			if e := recover(); e != nil {
+				if e == http.ErrAbortHandler {
+					panic(e)
+				}
+				r.dumpPanic(w, e)
+				http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}
+		}()
+		next.ServeHTTP(rw, req)
+	})
+}
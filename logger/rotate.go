@@ -0,0 +1,284 @@
+package logger
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RotateConfig はRotatingWriterのローテート条件
+type RotateConfig struct {
+	// MaxSize はファイルサイズの上限(byte)。書き込むとこれを超える場合にローテートする。0なら無効
+	MaxSize int64
+	// Daily がtrueなら日付(ローカルタイム)が変わった最初の書き込みでローテートする
+	Daily bool
+	// MaxBackups は残す世代数。0ならすべて残す
+	MaxBackups int
+	// Compress がtrueならローテートしたファイルをgzipで圧縮する
+	// 圧縮は別のgoroutineで行うので、その間もWriteは待たされない
+	Compress bool
+	// OnError はWrite中のローテートや圧縮に失敗したときに呼ばれる。nilなら標準エラー出力に書き出す
+	// ローテートに失敗してもpathを開き直して書き込みは続ける
+	OnError func(error)
+}
+
+// RotatingWriter はlogrotateのようにファイルを切り替えるio.Writer
+// 古いファイルは app.log.1, app.log.2 ... (圧縮時は app.log.1.gz) の順に古くなる
+// log.SetOutputにそのまま渡せて、複数のgoroutineから同時に書き込んでも問題ない
+type RotatingWriter struct {
+	mu     sync.Mutex
+	path   string
+	conf   RotateConfig
+	file   *os.File
+	closed bool
+	size   int64
+	day    int
+
+	// 圧縮中のファイルを次のローテートでずらさないように待つ
+	compressing sync.WaitGroup
+}
+
+// NewRotatingWriter はpathを追記モードで開く。ディレクトリは作らない
+func NewRotatingWriter(path string, conf RotateConfig) (*RotatingWriter, error) {
+	w := &RotatingWriter{path: path, conf: conf}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func dayKey(t time.Time) int {
+	y, m, d := t.Date()
+	return y*10000 + int(m)*100 + d
+}
+
+func (w *RotatingWriter) open() error {
+	f, err := os.OpenFile(w.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.file = f
+	w.size = info.Size()
+	// 既存のファイルに追記する場合は最終更新日をそのファイルの日付とする
+	w.day = dayKey(time.Now())
+	if w.size > 0 {
+		w.day = dayKey(info.ModTime())
+	}
+	return nil
+}
+
+func (w *RotatingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+	if w.file != nil && w.shouldRotate(len(p)) {
+		if err := w.rotate(); err != nil {
+			w.report(err)
+		}
+	}
+	// ローテートやReopenで開き直せなかった場合はここで再度開く
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *RotatingWriter) shouldRotate(n int) bool {
+	if w.conf.Daily && dayKey(time.Now()) != w.day {
+		return true
+	}
+	// 空のファイルなら上限を超える1行でもそのまま書き込む
+	return w.conf.MaxSize > 0 && w.size > 0 && w.size+int64(n) > w.conf.MaxSize
+}
+
+// Rotate は条件に関係なくローテートする
+func (w *RotatingWriter) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return os.ErrClosed
+	}
+	return w.rotate()
+}
+
+// 途中で失敗してもpathを開き直し、以降のWriteが書き込めるようにする
+// ローテートのエラーと開き直しのエラーが両方ある場合は開き直しの方を返す
+func (w *RotatingWriter) rotate() error {
+	err := w.rotateFiles()
+	if w.file == nil {
+		if oerr := w.open(); oerr != nil {
+			return oerr
+		}
+	}
+	return err
+}
+
+func (w *RotatingWriter) rotateFiles() error {
+	if w.file != nil {
+		err := w.file.Close()
+		w.file = nil
+		if err != nil {
+			return err
+		}
+	}
+
+	w.compressing.Wait()
+	if err := w.shift(); err != nil {
+		return err
+	}
+	name := w.backupName(1, false)
+	if err := os.Rename(w.path, name); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if w.conf.Compress {
+		w.compressing.Add(1)
+		go func() {
+			defer w.compressing.Done()
+			if err := gzipFile(name); err != nil {
+				w.report(err)
+			}
+		}()
+	}
+	return nil
+}
+
+func (w *RotatingWriter) report(err error) {
+	if w.conf.OnError != nil {
+		w.conf.OnError(err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "logger: rotate %s: %v\n", w.path, err)
+}
+
+func (w *RotatingWriter) backupName(i int, gz bool) string {
+	name := w.path + "." + strconv.Itoa(i)
+	if gz {
+		name += ".gz"
+	}
+	return name
+}
+
+// 途中でCompressを切り替えても大丈夫なように両方探す
+func (w *RotatingWriter) existingBackup(i int) (string, bool) {
+	for _, gz := range []bool{false, true} {
+		name := w.backupName(i, gz)
+		if _, err := os.Stat(name); err == nil {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// .1 -> .2, .2 -> .3 のように世代をずらし、MaxBackupsを超えたものは消す
+func (w *RotatingWriter) shift() error {
+	n := w.conf.MaxBackups
+	if n <= 0 {
+		for n = 0; ; n++ {
+			if _, ok := w.existingBackup(n + 1); !ok {
+				break
+			}
+		}
+	}
+	for i := n; i >= 1; i-- {
+		name, ok := w.existingBackup(i)
+		if !ok {
+			continue
+		}
+		if w.conf.MaxBackups > 0 && i >= w.conf.MaxBackups {
+			if err := os.Remove(name); err != nil {
+				return err
+			}
+			continue
+		}
+		gz := name == w.backupName(i, true)
+		if err := os.Rename(name, w.backupName(i+1, gz)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func gzipFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(name+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	// 失敗した場合は中途半端な.gzを消して元のファイルを残す
+	zw := gzip.NewWriter(dst)
+	_, err = io.Copy(zw, src)
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(name + ".gz")
+		return err
+	}
+	return os.Remove(name)
+}
+
+// Reopen はファイルを閉じて同じパスで開き直す
+// logrotateなど外部でファイルをmvされた後に呼ぶと新しいファイルに書き込むようになる
+// 切り替え中のWriteはロックで待たされるので、行が失われたり混ざったりすることはない
+func (w *RotatingWriter) Reopen() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return os.ErrClosed
+	}
+	if w.file != nil {
+		err := w.file.Close()
+		w.file = nil
+		if err != nil {
+			// 閉じられなくても開き直しはしておく
+			if oerr := w.open(); oerr != nil {
+				return oerr
+			}
+			return err
+		}
+	}
+	return w.open()
+}
+
+// Close はファイルを閉じる。以降のWriteはos.ErrClosedを返す
+// 圧縮中のファイルがあれば終わるまで待つ
+func (w *RotatingWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	w.compressing.Wait()
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
@@ -0,0 +1,146 @@
+package logger
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func readFile(t *testing.T, name string) string {
+	t.Helper()
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestRotatingWriterMaxSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	w, err := NewRotatingWriter(path, RotateConfig{MaxSize: 10, MaxBackups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"aaaaaaaa\n", "bbbbbbbb\n", "cccccccc\n", "dddddddd\n"} {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Close()
+
+	tests := []struct{ name, want string }{
+		{path, "dddddddd\n"},
+		{path + ".1", "cccccccc\n"},
+		{path + ".2", "bbbbbbbb\n"},
+	}
+	for _, tt := range tests {
+		if got := readFile(t, tt.name); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("MaxBackups exceeded: %v", err)
+	}
+}
+
+func TestRotatingWriterCompress(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	w, err := NewRotatingWriter(path, RotateConfig{Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("old\n"))
+	if err := w.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("new\n"))
+	if err := w.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	// Closeは圧縮が終わるまで待つ
+	w.Close()
+
+	for name, want := range map[string]string{path + ".1.gz": "new\n", path + ".2.gz": "old\n"} {
+		f, err := os.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := ioutil.ReadAll(zr)
+		f.Close()
+		if string(b) != want {
+			t.Errorf("%s = %q, want %q", name, b, want)
+		}
+	}
+	if _, err := os.Stat(path + ".1"); !os.IsNotExist(err) {
+		t.Errorf("uncompressed backup left: %v", err)
+	}
+}
+
+func TestRotatingWriterRecoversFromRotateError(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	// app.log.1 が消せないのでローテートに失敗する
+	if err := os.MkdirAll(filepath.Join(path+".1", "x"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	var errs []error
+	w, err := NewRotatingWriter(path, RotateConfig{
+		MaxSize:    10,
+		MaxBackups: 1,
+		OnError:    func(err error) { errs = append(errs, err) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	w.Write([]byte("aaaaaaaa\n"))
+	if _, err := w.Write([]byte("bbbbbbbb\n")); err != nil {
+		t.Fatalf("Write after failed rotate: %v", err)
+	}
+	if len(errs) != 1 {
+		t.Fatalf("OnError called %d times, want 1", len(errs))
+	}
+	if err := w.Rotate(); err == nil {
+		t.Error("Rotate should report the error")
+	}
+
+	// 原因がなくなれば次のWriteでローテートされる
+	os.RemoveAll(path + ".1")
+	if _, err := w.Write([]byte("cccccccc\n")); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, path+".1"); got != "aaaaaaaa\nbbbbbbbb\n" {
+		t.Errorf("backup = %q", got)
+	}
+	if got := readFile(t, path); got != "cccccccc\n" {
+		t.Errorf("current = %q", got)
+	}
+}
+
+func TestRotatingWriterClosed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	w, err := NewRotatingWriter(path, RotateConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	if _, err := w.Write([]byte("x\n")); err != os.ErrClosed {
+		t.Errorf("Write after Close = %v", err)
+	}
+	if err := w.Reopen(); err != os.ErrClosed {
+		t.Errorf("Reopen after Close = %v", err)
+	}
+	if got := readFile(t, path); strings.Contains(got, "x") {
+		t.Errorf("written after Close: %q", got)
+	}
+}
@@ -0,0 +1,138 @@
+package logger
+
+import (
+	"sync"
+	"time"
+)
+
+// SamplingConfig はSamplerの間引き条件
+// Interval ごとに、同じレベル・同じメッセージのEntryを最初のFirst件は出力し、
+// それ以降はThereafter件ごとに1件だけ出力する。Thereafterが0ならそれ以降はすべて捨てる
+type SamplingConfig struct {
+	Interval   time.Duration
+	First      int
+	Thereafter int
+}
+
+type sampleKey struct {
+	level   Level
+	message string
+}
+
+type sampleCount struct {
+	n          int
+	suppressed int
+}
+
+// Sampler は同じメッセージが大量に出力されるときに間引くSink
+// semaphore.goのように数千件のタスクを回すと"starting number"のような行で埋まってしまうので、
+// メッセージ(フィールドを除いたもの)をテンプレートとして数える
+// そのため可変の値はメッセージに埋め込まずFieldで渡すこと
+//
+//	s := logger.NewSampler(sink, logger.SamplingConfig{Interval: time.Second, First: 10, Thereafter: 100})
+//	defer s.Close()
+//	l := logger.NewStructured(s)
+//	l.Info("starting number", logger.Int("number", i))
+//
+// Intervalごとに、間引いた件数を"logger: sampled out"というEntryで出力する
+type Sampler struct {
+	sink Sink
+	conf SamplingConfig
+
+	mu     sync.Mutex
+	counts map[sampleKey]*sampleCount
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// NewSampler はsinkへの出力を間引くSamplerを作る
+// Intervalが0以下の場合は1秒とする
+func NewSampler(sink Sink, conf SamplingConfig) *Sampler {
+	if conf.Interval <= 0 {
+		conf.Interval = time.Second
+	}
+	s := &Sampler{
+		sink:   sink,
+		conf:   conf,
+		counts: map[sampleKey]*sampleCount{},
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+func (s *Sampler) WriteEntry(e *Entry) error {
+	key := sampleKey{e.Level, e.Message}
+
+	s.mu.Lock()
+	c, ok := s.counts[key]
+	if !ok {
+		c = &sampleCount{}
+		s.counts[key] = c
+	}
+	c.n++
+	pass := c.n <= s.conf.First ||
+		(s.conf.Thereafter > 0 && (c.n-s.conf.First)%s.conf.Thereafter == 0)
+	if !pass {
+		c.suppressed++
+	}
+	s.mu.Unlock()
+
+	if !pass {
+		return nil
+	}
+	return s.sink.WriteEntry(e)
+}
+
+func (s *Sampler) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.conf.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.summarize()
+		case <-s.stop:
+			s.summarize()
+			return
+		}
+	}
+}
+
+// カウントをリセットして、間引いた分があればその件数を出力する
+func (s *Sampler) summarize() {
+	s.mu.Lock()
+	counts := s.counts
+	s.counts = map[sampleKey]*sampleCount{}
+	s.mu.Unlock()
+
+	now := time.Now()
+	for key, c := range counts {
+		if c.suppressed == 0 {
+			continue
+		}
+		s.sink.WriteEntry(&Entry{
+			Time:    now,
+			Level:   key.level,
+			Message: "logger: sampled out",
+			Fields: []Field{
+				String("sampled_msg", key.message),
+				Int("suppressed", c.suppressed),
+				Int("total", c.n),
+				Duration("interval", s.conf.Interval),
+			},
+		})
+	}
+}
+
+// Close は間引いた件数を出力してから止める
+// sinkのCloseは呼ばない
+func (s *Sampler) Close() error {
+	s.stopOnce.Do(func() { close(s.stop) })
+	<-s.done
+	return nil
+}
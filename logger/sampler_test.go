@@ -0,0 +1,104 @@
+package logger
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSampler(t *testing.T) {
+	tests := []struct {
+		name           string
+		first          int
+		thereafter     int
+		n              int
+		wantPassed     int
+		wantSuppressed int
+	}{
+		{"first only", 3, 0, 10, 3, 7},
+		{"thereafter", 3, 2, 10, 6, 4},
+		{"no first", 0, 5, 12, 2, 10},
+		{"under first", 10, 0, 5, 5, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &recordSink{}
+			var summaries []*Entry
+			s := NewSampler(sinkFunc(func(e *Entry) error {
+				if e.Message == "logger: sampled out" {
+					summaries = append(summaries, e)
+					return nil
+				}
+				return rec.WriteEntry(e)
+			}), SamplingConfig{Interval: time.Hour, First: tt.first, Thereafter: tt.thereafter})
+
+			l := NewStructured(s)
+			for i := 0; i < tt.n; i++ {
+				l.Info("starting number", Int("number", i))
+			}
+			s.Close()
+
+			if got := len(rec.messages()); got != tt.wantPassed {
+				t.Errorf("passed %d, want %d", got, tt.wantPassed)
+			}
+			if tt.wantSuppressed == 0 {
+				if len(summaries) != 0 {
+					t.Errorf("unexpected summary: %+v", summaries)
+				}
+				return
+			}
+			if len(summaries) != 1 {
+				t.Fatalf("got %d summaries, want 1", len(summaries))
+			}
+			fields := map[string]interface{}{}
+			for _, f := range summaries[0].Fields {
+				fields[f.Key] = f.Value
+			}
+			if fields["sampled_msg"] != "starting number" ||
+				fields["suppressed"] != int64(tt.wantSuppressed) ||
+				fields["total"] != int64(tt.n) ||
+				summaries[0].Level != InfoLevel {
+				t.Errorf("summary = %v %v", summaries[0].Level, fields)
+			}
+		})
+	}
+}
+
+// レベルとメッセージが違えば別々に数える
+func TestSamplerKeys(t *testing.T) {
+	rec := &recordSink{}
+	s := NewSampler(rec, SamplingConfig{Interval: time.Hour, First: 1})
+	l := NewStructured(s)
+	for i := 0; i < 3; i++ {
+		l.Info("a")
+		l.Info("b")
+		l.Error("a")
+	}
+	s.Close()
+
+	got := rec.messages()
+	// それぞれ1件ずつと、間引いた件数が3つ
+	if len(got) != 6 || got[0] != "a" || got[1] != "b" || got[2] != "a" {
+		t.Errorf("got %v", got)
+	}
+}
+
+// Intervalごとに数え直す
+func TestSamplerInterval(t *testing.T) {
+	rec := &recordSink{}
+	s := NewSampler(rec, SamplingConfig{Interval: 20 * time.Millisecond, First: 1})
+	defer s.Close()
+	l := NewStructured(s)
+
+	l.Info("spam")
+	l.Info("spam")
+	deadline := time.Now().Add(5 * time.Second)
+	for len(rec.messages()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	l.Info("spam")
+
+	got := rec.messages()
+	if len(got) != 3 || got[0] != "spam" || got[1] != "logger: sampled out" || got[2] != "spam" {
+		t.Errorf("got %v", got)
+	}
+}
@@ -0,0 +1,372 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ShipperConfig はHTTPShipperの送信先と送り方
+type ShipperConfig struct {
+	// URL はNDJSONをPOSTする先
+	URL string
+	// Client が nil ならTimeoutが10秒のhttp.Client
+	// 収集サーバーが応答しないと送信が止まり、キューが一杯になるとWriteEntryも止まるので
+	// 指定する場合もTimeoutを設定しておく
+	Client *http.Client
+	// Encoder が nil なら時刻をマイクロ秒まで出力するJSONEncoder
+	// 1行1Entryのjsonになるものを指定する
+	Encoder Encoder
+
+	// BatchSize 件溜まるか、FlushInterval 経過するごとに送る。0ならそれぞれ100件、1秒
+	BatchSize     int
+	FlushInterval time.Duration
+	// QueueSize はWriteEntryと送信の間のキューの長さ。0ならBatchSizeの10倍
+	// キューが一杯のときはアプリケーションを止めないよう、WriteEntryはその行を捨ててDroppedで数える
+	QueueSize int
+
+	// MaxRetries 回まで Backoff, Backoff*2, Backoff*4 ... と間を空けて送り直す
+	// 0ならそれぞれ3回、500ms
+	MaxRetries int
+	Backoff    time.Duration
+
+	// SpoolPath は送れなかったバッチを書き出すファイル
+	// 次の送信のときに先頭から送り直す。空ならそのバッチは捨てる
+	// 送り直しに失敗した後は、Backoffから倍々に(最大1分)待つ間、新しいバッチも送らずにそのまま書き出す
+	// 429と5xx以外の4xxで断られたバッチは何度送っても同じなので退避せずに捨てる
+	SpoolPath string
+
+	// OnError は送信に失敗したときに呼ばれる。nilなら標準エラー出力に書き出す
+	OnError func(error)
+}
+
+// HTTPShipper はEntryをまとめてHTTPでログ収集サーバーに送るSink
+// http/clientのpost()と同じくhttp.Postするだけだが、
+// 送れなかった場合にリトライし、それでもだめならファイルに退避して後で送り直す
+//
+// 終了時にキューに残っている分を送るため、必ずdeferでCloseする
+type HTTPShipper struct {
+	conf ShipperConfig
+
+	queue   chan []byte
+	flushes chan chan struct{}
+	done    chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+
+	// run のgoroutineだけが触る
+	// 送信に失敗したら、retryAtまでは送らずに退避する
+	retryAt   time.Time
+	retryWait time.Duration
+
+	sent    uint64
+	spooled uint64
+	dropped uint64
+}
+
+const (
+	defaultShipperTimeout = 10 * time.Second
+	maxShipperRetryWait   = time.Minute
+)
+
+// NewHTTPShipper はconfで送信するHTTPShipperを作る
+func NewHTTPShipper(conf ShipperConfig) *HTTPShipper {
+	if conf.Client == nil {
+		conf.Client = &http.Client{Timeout: defaultShipperTimeout}
+	}
+	if conf.Encoder == nil {
+		conf.Encoder = NewJSONEncoder(log.LstdFlags | log.Lmicroseconds)
+	}
+	if conf.BatchSize <= 0 {
+		conf.BatchSize = 100
+	}
+	if conf.FlushInterval <= 0 {
+		conf.FlushInterval = time.Second
+	}
+	if conf.QueueSize <= 0 {
+		conf.QueueSize = conf.BatchSize * 10
+	}
+	if conf.MaxRetries <= 0 {
+		conf.MaxRetries = 3
+	}
+	if conf.Backoff <= 0 {
+		conf.Backoff = 500 * time.Millisecond
+	}
+
+	s := &HTTPShipper{
+		conf:    conf,
+		queue:   make(chan []byte, conf.QueueSize),
+		flushes: make(chan chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// WriteEntry はeをエンコードしてキューに積む
+// キューが一杯の場合は待たずに捨ててDroppedで数える
+func (s *HTTPShipper) WriteEntry(e *Entry) error {
+	b, err := s.conf.Encoder.Encode(e)
+	if err != nil {
+		return err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return os.ErrClosed
+	}
+	select {
+	case s.queue <- b:
+	default:
+		atomic.AddUint64(&s.dropped, 1)
+	}
+	return nil
+}
+
+func (s *HTTPShipper) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.conf.FlushInterval)
+	defer ticker.Stop()
+
+	var batch [][]byte
+	flush := func() {
+		s.ship(batch)
+		batch = nil
+	}
+
+	for {
+		select {
+		case b, ok := <-s.queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, b)
+			if len(batch) >= s.conf.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case ch := <-s.flushes:
+			// Flushより前にWriteEntryされた分はキューに入っているので先に取り出す
+			for len(s.queue) > 0 {
+				batch = append(batch, <-s.queue)
+			}
+			flush()
+			close(ch)
+		}
+	}
+}
+
+// Flush はそれまでにWriteEntryされた分を送り終わる(もしくは退避する)まで待つ
+func (s *HTTPShipper) Flush() {
+	s.mu.RLock()
+	if s.closed {
+		s.mu.RUnlock()
+		return
+	}
+	ch := make(chan struct{})
+	s.flushes <- ch
+	s.mu.RUnlock()
+	<-ch
+}
+
+// Close はキューに残っている分を送ってから止める
+func (s *HTTPShipper) Close() error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+	<-s.done
+	return nil
+}
+
+// Sent, Spooled, Dropped は送信できた件数、退避した件数、捨てた件数を返す
+func (s *HTTPShipper) Sent() uint64    { return atomic.LoadUint64(&s.sent) }
+func (s *HTTPShipper) Spooled() uint64 { return atomic.LoadUint64(&s.spooled) }
+func (s *HTTPShipper) Dropped() uint64 { return atomic.LoadUint64(&s.dropped) }
+
+// 退避済みのものがあればそちらを先に送って順番を守る
+// 退避済みのものが送れない場合や、前の失敗から待っている間は、このバッチも送らずに退避する
+// 収集サーバーが応答しないときに、タイムアウトを待つ送信を毎回繰り返さないようにするため
+func (s *HTTPShipper) ship(batch [][]byte) {
+	if s.hasSpool() {
+		if time.Now().Before(s.retryAt) {
+			s.spool(batch)
+			return
+		}
+		if err := s.replay(); err != nil {
+			s.report(err)
+			s.backoff()
+			s.spool(batch)
+			return
+		}
+		s.retryWait = 0
+	}
+	if len(batch) == 0 {
+		return
+	}
+
+	body := bytes.Join(batch, nil)
+	backoff := s.conf.Backoff
+	var err error
+	for i := 0; i <= s.conf.MaxRetries; i++ {
+		if i > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		var retry bool
+		if retry, err = s.post(body); err == nil {
+			atomic.AddUint64(&s.sent, uint64(len(batch)))
+			return
+		}
+		if !retry {
+			s.report(err)
+			atomic.AddUint64(&s.dropped, uint64(len(batch)))
+			return
+		}
+	}
+	s.report(err)
+	s.backoff()
+	s.spool(batch)
+}
+
+// 次に送るまでの間隔をBackoffから倍々にする
+func (s *HTTPShipper) backoff() {
+	switch {
+	case s.retryWait == 0:
+		s.retryWait = s.conf.Backoff
+	case s.retryWait < maxShipperRetryWait:
+		s.retryWait *= 2
+	}
+	if s.retryWait > maxShipperRetryWait {
+		s.retryWait = maxShipperRetryWait
+	}
+	s.retryAt = time.Now().Add(s.retryWait)
+}
+
+// 戻り値のboolは送り直す意味があるかどうか
+// 通信エラー、429、5xxは送り直し、それ以外の4xxは何度送っても同じなので送り直さない
+func (s *HTTPShipper) post(body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, s.conf.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+
+	res, err := s.conf.Client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return false, nil
+	}
+	err = fmt.Errorf("logger: shipper: %s returned %s", s.conf.URL, res.Status)
+	return res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500, err
+}
+
+func (s *HTTPShipper) hasSpool() bool {
+	if s.conf.SpoolPath == "" {
+		return false
+	}
+	info, err := os.Stat(s.conf.SpoolPath)
+	return err == nil && info.Size() > 0
+}
+
+func (s *HTTPShipper) spool(batch [][]byte) {
+	if len(batch) == 0 {
+		return
+	}
+	if s.conf.SpoolPath == "" {
+		atomic.AddUint64(&s.dropped, uint64(len(batch)))
+		return
+	}
+
+	f, err := os.OpenFile(s.conf.SpoolPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err == nil {
+		_, err = f.Write(bytes.Join(batch, nil))
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		s.report(err)
+		atomic.AddUint64(&s.dropped, uint64(len(batch)))
+		return
+	}
+	atomic.AddUint64(&s.spooled, uint64(len(batch)))
+}
+
+// 退避したファイルをBatchSizeごとに1回ずつ送る
+// 途中で失敗した場合は送れなかった分だけをファイルに残す
+// 送り直しても無駄なエラーで断られた分は、後ろを詰まらせないように捨てて次に進む
+func (s *HTTPShipper) replay() error {
+	data, err := ioutil.ReadFile(s.conf.SpoolPath)
+	if err != nil {
+		return err
+	}
+
+	var lines [][]byte
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 64*1024), len(data)+1)
+	for sc.Scan() {
+		if len(sc.Bytes()) > 0 {
+			lines = append(lines, append(append([]byte(nil), sc.Bytes()...), '\n'))
+		}
+	}
+
+	for len(lines) > 0 {
+		n := s.conf.BatchSize
+		if n > len(lines) {
+			n = len(lines)
+		}
+		retry, err := s.post(bytes.Join(lines[:n], nil))
+		switch {
+		case err == nil:
+			atomic.AddUint64(&s.sent, uint64(n))
+		case !retry:
+			s.report(err)
+			atomic.AddUint64(&s.dropped, uint64(n))
+		default:
+			if werr := s.rewriteSpool(lines); werr != nil {
+				return werr
+			}
+			return err
+		}
+		lines = lines[n:]
+	}
+	return os.Remove(s.conf.SpoolPath)
+}
+
+// 途中で落ちても退避したものが消えないよう、一時ファイルに書いてから置き換える
+func (s *HTTPShipper) rewriteSpool(lines [][]byte) error {
+	tmp := s.conf.SpoolPath + ".tmp"
+	if err := ioutil.WriteFile(tmp, bytes.Join(lines, nil), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.conf.SpoolPath)
+}
+
+func (s *HTTPShipper) report(err error) {
+	if s.conf.OnError != nil {
+		s.conf.OnError(err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "logger: shipper: %v\n", err)
+}
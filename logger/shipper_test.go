@@ -0,0 +1,265 @@
+package logger
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// collector はNDJSONを受け取って1行ずつ記録するテスト用の収集サーバー
+// statusが0以外ならそのステータスで断る
+type collector struct {
+	mu     sync.Mutex
+	lines  []string
+	posts  int
+	status func(body string) int
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b, _ := ioutil.ReadAll(r.Body)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.posts++
+	if c.status != nil {
+		if code := c.status(string(b)); code != 0 {
+			w.WriteHeader(code)
+			return
+		}
+	}
+	sc := bufio.NewScanner(strings.NewReader(string(b)))
+	for sc.Scan() {
+		c.lines = append(c.lines, sc.Text())
+	}
+}
+
+func (c *collector) received() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.lines...)
+}
+
+func (c *collector) setStatus(f func(string) int) {
+	c.mu.Lock()
+	c.status = f
+	c.mu.Unlock()
+}
+
+func testShipperConfig(url string) ShipperConfig {
+	return ShipperConfig{
+		URL:           url,
+		Encoder:       NewJSONEncoder(0),
+		BatchSize:     10,
+		FlushInterval: time.Hour,
+		MaxRetries:    2,
+		Backoff:       time.Millisecond,
+		OnError:       func(error) {},
+	}
+}
+
+func TestHTTPShipperBatches(t *testing.T) {
+	c := &collector{}
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	s := NewHTTPShipper(testShipperConfig(srv.URL))
+	l := NewStructured(s)
+	for i := 0; i < 25; i++ {
+		l.Info("spam", Int("i", i))
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := c.received()
+	if len(got) != 25 {
+		t.Fatalf("received %d lines, want 25", len(got))
+	}
+	if got[0] != `{"level":"info","msg":"spam","i":0}` || got[24] != `{"level":"info","msg":"spam","i":24}` {
+		t.Errorf("unexpected order: %q ... %q", got[0], got[24])
+	}
+	if c.posts != 3 {
+		t.Errorf("posts = %d, want 3", c.posts)
+	}
+	if s.Sent() != 25 {
+		t.Errorf("Sent() = %d, want 25", s.Sent())
+	}
+}
+
+func TestHTTPShipperRetry(t *testing.T) {
+	c := &collector{}
+	fails := 2
+	c.status = func(string) int {
+		if fails > 0 {
+			fails--
+			return http.StatusServiceUnavailable
+		}
+		return 0
+	}
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	s := NewHTTPShipper(testShipperConfig(srv.URL))
+	NewStructured(s).Info("spam")
+	s.Close()
+
+	if got := c.received(); len(got) != 1 {
+		t.Fatalf("received %v, want 1 line", got)
+	}
+	if c.posts != 3 {
+		t.Errorf("posts = %d, want 3", c.posts)
+	}
+}
+
+func TestHTTPShipperSpoolAndReplay(t *testing.T) {
+	c := &collector{}
+	c.status = func(string) int { return http.StatusInternalServerError }
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	conf := testShipperConfig(srv.URL)
+	conf.SpoolPath = filepath.Join(t.TempDir(), "spool")
+	s := NewHTTPShipper(conf)
+	defer s.Close()
+	l := NewStructured(s)
+
+	l.Info("first")
+	s.Flush()
+	l.Info("second")
+	s.Flush()
+	if s.Spooled() != 2 || len(c.received()) != 0 {
+		t.Fatalf("Spooled() = %d, received %v", s.Spooled(), c.received())
+	}
+
+	// 復旧したら、待ち時間(Backoff)が過ぎた後の送信で退避した分を先に送る
+	c.setStatus(nil)
+	time.Sleep(10 * time.Millisecond)
+	l.Info("third")
+	s.Flush()
+
+	got := c.received()
+	want := []string{"first", "second", "third"}
+	if len(got) != len(want) {
+		t.Fatalf("received %v, want %v", got, want)
+	}
+	for i, w := range want {
+		if !strings.Contains(got[i], `"msg":"`+w+`"`) {
+			t.Errorf("line %d = %s, want msg %s", i, got[i], w)
+		}
+	}
+	if _, err := os.Stat(conf.SpoolPath); !os.IsNotExist(err) {
+		t.Errorf("spool file should be removed: %v", err)
+	}
+}
+
+func TestHTTPShipperRejectedBatchDoesNotBlock(t *testing.T) {
+	c := &collector{}
+	c.status = func(string) int { return http.StatusBadGateway }
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	conf := testShipperConfig(srv.URL)
+	conf.BatchSize = 1
+	conf.SpoolPath = filepath.Join(t.TempDir(), "spool")
+	s := NewHTTPShipper(conf)
+	defer s.Close()
+	l := NewStructured(s)
+
+	// 落ちている間に退避したものが、復旧後に400で断られる
+	l.Info("bad")
+	s.Flush()
+	c.setStatus(func(body string) int {
+		if strings.Contains(body, "bad") {
+			return http.StatusBadRequest
+		}
+		return 0
+	})
+	time.Sleep(10 * time.Millisecond)
+	for i := 0; i < 5; i++ {
+		l.Info("good", Int("i", i))
+		s.Flush()
+	}
+
+	if got := c.received(); len(got) != 5 {
+		t.Fatalf("received %d lines, want 5: %v", len(got), got)
+	}
+	if s.Dropped() != 1 {
+		t.Errorf("Dropped() = %d, want 1", s.Dropped())
+	}
+	if _, err := os.Stat(conf.SpoolPath); !os.IsNotExist(err) {
+		t.Errorf("spool file should be removed: %v", err)
+	}
+
+	// 退避していないものも400なら捨てる
+	l.Info("bad again")
+	s.Flush()
+	if s.Dropped() != 2 || s.hasSpool() {
+		t.Errorf("Dropped() = %d, hasSpool() = %v", s.Dropped(), s.hasSpool())
+	}
+}
+
+func TestHTTPShipperDefaultTimeout(t *testing.T) {
+	s := NewHTTPShipper(ShipperConfig{URL: "http://127.0.0.1:0"})
+	defer s.Close()
+	if s.conf.Client.Timeout == 0 {
+		t.Error("default client has no timeout")
+	}
+}
+
+// 応答しない収集サーバーに対して、WriteEntryが止まらず、
+// 一度失敗した後は待ち時間の間は送らずに退避する
+func TestHTTPShipperHungCollector(t *testing.T) {
+	release := make(chan struct{})
+	var mu sync.Mutex
+	posts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		posts++
+		mu.Unlock()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	conf := testShipperConfig(srv.URL)
+	conf.Client = &http.Client{Timeout: 50 * time.Millisecond}
+	conf.BatchSize = 1
+	conf.QueueSize = 2
+	conf.MaxRetries = 1
+	conf.Backoff = 200 * time.Millisecond
+	conf.SpoolPath = filepath.Join(t.TempDir(), "spool")
+	s := NewHTTPShipper(conf)
+	defer s.Close()
+
+	l := NewStructured(s)
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		l.Info("spam", Int("i", i))
+	}
+	if d := time.Since(start); d > 50*time.Millisecond {
+		t.Errorf("WriteEntry blocked for %v", d)
+	}
+	s.Flush()
+
+	if s.Dropped() == 0 {
+		t.Error("entries should be dropped while the queue is full")
+	}
+	if s.Sent() != 0 || s.Spooled()+s.Dropped() != 100 {
+		t.Errorf("Sent() = %d, Spooled() = %d, Dropped() = %d", s.Sent(), s.Spooled(), s.Dropped())
+	}
+	// 最初のバッチの2回(リトライ1回)だけ送り、後は待ち時間の間なので退避だけする
+	mu.Lock()
+	defer mu.Unlock()
+	if posts != 2 {
+		t.Errorf("posts = %d, want 2", posts)
+	}
+}
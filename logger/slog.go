@@ -0,0 +1,212 @@
+package logger
+
+import (
+	"context"
+	"io"
+	"log"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// ToSlogLevel はLevelを対応するslog.Levelにする
+// slogにはFATALが無いので、ErrorよりLevelError-LevelWarn(=4)だけ大きい値にする
+func ToSlogLevel(lv Level) slog.Level {
+	switch {
+	case lv <= DebugLevel:
+		return slog.LevelDebug
+	case lv == InfoLevel:
+		return slog.LevelInfo
+	case lv == WarnLevel:
+		return slog.LevelWarn
+	case lv == ErrorLevel:
+		return slog.LevelError
+	}
+	return slog.LevelError + 4
+}
+
+// FromSlogLevel はslog.Levelを対応するLevelにする
+// slogのレベルは任意の整数なので、間の値は下のレベルに丸める (LevelInfo+2はINFO)
+func FromSlogLevel(lv slog.Level) Level {
+	switch {
+	case lv < slog.LevelInfo:
+		return DebugLevel
+	case lv < slog.LevelWarn:
+		return InfoLevel
+	case lv < slog.LevelError:
+		return WarnLevel
+	case lv < slog.LevelError+4:
+		return ErrorLevel
+	}
+	return FatalLevel
+}
+
+type slogLeveler struct{ l Leveler }
+
+func (s slogLeveler) Level() slog.Level { return ToSlogLevel(s.l.Level()) }
+
+// SlogLeveler はLevelerをslog.Levelerにする
+// StructuredLoggerやLevelRegistryに登録したロガーと、SlogHandlerの最低レベルを連動させたいときに使う
+func SlogLeveler(l Leveler) slog.Leveler {
+	return slogLeveler{l}
+}
+
+// SlogHandler はslog.Handlerの実装
+// 出力はSinkに渡すので、RotatingWriter, AsyncWriter, RingBuffer, Redactorなど
+// このパッケージの出力先をそのままslogから使える
+//
+//	sink := redactor.Sink(logger.NewWriterSink(rotating, logger.NewJSONEncoder(log.LstdFlags)))
+//	slog.SetDefault(slog.New(logger.NewSlogHandler(sink, slog.LevelInfo)))
+//
+// グループは"request.id"のようにキーを.でつないだフィールドになる
+type SlogHandler struct {
+	sink   Sink
+	level  slog.Leveler
+	fields []Field
+	group  string
+}
+
+// NewSlogHandler はsinkに書き出すSlogHandlerを作る。levelがnilの場合はslog.LevelInfo
+func NewSlogHandler(sink Sink, level slog.Leveler) *SlogHandler {
+	if level == nil {
+		level = slog.LevelInfo
+	}
+	return &SlogHandler{sink: sink, level: level}
+}
+
+func (h *SlogHandler) Enabled(_ context.Context, lv slog.Level) bool {
+	return lv >= h.level.Level()
+}
+
+// Handle はrをEntryにしてsinkに渡す
+// slog.Handlerの決まりに従い、r.Timeがゼロ値の場合は時刻を出力しない
+func (h *SlogHandler) Handle(_ context.Context, r slog.Record) error {
+	e := &Entry{
+		Time:    r.Time,
+		Level:   FromSlogLevel(r.Level),
+		Message: r.Message,
+		Fields:  make([]Field, 0, len(h.fields)+r.NumAttrs()),
+	}
+	e.Fields = append(e.Fields, h.fields...)
+	r.Attrs(func(a slog.Attr) bool {
+		e.Fields = appendAttr(e.Fields, h.group, a)
+		return true
+	})
+	return h.sink.WriteEntry(e)
+}
+
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *h
+	c.fields = make([]Field, 0, len(h.fields)+len(attrs))
+	c.fields = append(c.fields, h.fields...)
+	for _, a := range attrs {
+		c.fields = appendAttr(c.fields, h.group, a)
+	}
+	return &c
+}
+
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	c := *h
+	c.group = h.group + name + "."
+	return &c
+}
+
+func appendAttr(fields []Field, prefix string, a slog.Attr) []Field {
+	v := a.Value.Resolve()
+	// slogのルールに従い空のAttrは無視する
+	if a.Key == "" && v.Kind() != slog.KindGroup {
+		return fields
+	}
+
+	key := prefix + a.Key
+	switch v.Kind() {
+	case slog.KindGroup:
+		attrs := v.Group()
+		if len(attrs) == 0 {
+			return fields
+		}
+		// キーの無いグループは中身を展開する
+		if a.Key != "" {
+			prefix = key + "."
+		}
+		for _, ga := range attrs {
+			fields = appendAttr(fields, prefix, ga)
+		}
+		return fields
+	case slog.KindString:
+		return append(fields, String(key, v.String()))
+	case slog.KindInt64:
+		return append(fields, Int64(key, v.Int64()))
+	case slog.KindUint64:
+		return append(fields, Uint64(key, v.Uint64()))
+	case slog.KindFloat64:
+		return append(fields, Float64(key, v.Float64()))
+	case slog.KindBool:
+		return append(fields, Bool(key, v.Bool()))
+	case slog.KindDuration:
+		return append(fields, Duration(key, v.Duration()))
+	case slog.KindTime:
+		return append(fields, Time(key, v.Time()))
+	}
+	return append(fields, Any(key, v.Any()))
+}
+
+// slogWriter は*log.Loggerの出力をslogに流すio.Writer
+type slogWriter struct {
+	h     slog.Handler
+	level slog.Level
+}
+
+// NewSlogWriter は書き込まれた1行をhに渡すio.Writerを作る
+// 行の先頭に"[DEBUG] "のようなレベル表記(LoggerやHandlers()のdebugロガーがつけるもの)があれば
+// そのレベルで、無ければlevelで出力する
+func NewSlogWriter(h slog.Handler, level slog.Level) io.Writer {
+	return &slogWriter{h: h, level: level}
+}
+
+func (w *slogWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimRight(string(p), "\n")
+	lv := w.level
+	if tagLv, rest, ok := cutLevelTag(msg); ok {
+		lv, msg = ToSlogLevel(tagLv), rest
+	}
+
+	ctx := context.Background()
+	if !w.h.Enabled(ctx, lv) {
+		return len(p), nil
+	}
+	if err := w.h.Handle(ctx, slog.NewRecord(time.Now(), lv, msg, 0)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// "[stdout] [DEBUG] 何かしらのデバッグ文言" のように、先頭に並んだ[...]の中からレベル表記を探して取り除く
+func cutLevelTag(msg string) (Level, string, bool) {
+	rest := msg
+	for strings.HasPrefix(rest, "[") {
+		end := strings.Index(rest, "] ")
+		if end < 0 {
+			break
+		}
+		if lv, err := ParseLevel(rest[1:end]); err == nil {
+			head := msg[:len(msg)-len(rest)]
+			return lv, head + rest[end+2:], true
+		}
+		rest = rest[end+2:]
+	}
+	return 0, msg, false
+}
+
+// RedirectToSlog はlの出力をhに流すようにする
+// 時刻はslog側でつけるので、lのフラグは0にする
+//
+//	debug := log.New(os.Stderr, "[DEBUG] ", log.LstdFlags)
+//	logger.RedirectToSlog(debug, handler, slog.LevelInfo)
+func RedirectToSlog(l *log.Logger, h slog.Handler, level slog.Level) {
+	l.SetFlags(0)
+	l.SetOutput(NewSlogWriter(h, level))
+}
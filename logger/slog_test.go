@@ -0,0 +1,71 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"log/slog"
+	"strings"
+	"testing"
+	"testing/slogtest"
+	"time"
+)
+
+// "G.a"のように.でつないだキーをslogtestが期待する入れ子のmapに戻す
+func nestKeys(flat map[string]any) map[string]any {
+	m := map[string]any{}
+	for k, v := range flat {
+		cur := m
+		parts := strings.Split(k, ".")
+		for _, p := range parts[:len(parts)-1] {
+			next, ok := cur[p].(map[string]any)
+			if !ok {
+				next = map[string]any{}
+				cur[p] = next
+			}
+			cur = next
+		}
+		cur[parts[len(parts)-1]] = v
+	}
+	return m
+}
+
+func TestSlogHandler(t *testing.T) {
+	buf := &bytes.Buffer{}
+	h := NewSlogHandler(NewWriterSink(buf, NewJSONEncoder(log.LstdFlags)), nil)
+
+	results := func() []map[string]any {
+		var ms []map[string]any
+		for _, line := range bytes.Split(buf.Bytes(), []byte{'\n'}) {
+			if len(line) == 0 {
+				continue
+			}
+			var m map[string]any
+			if err := json.Unmarshal(line, &m); err != nil {
+				t.Fatalf("%s: %v", line, err)
+			}
+			ms = append(ms, nestKeys(m))
+		}
+		return ms
+	}
+	if err := slogtest.TestHandler(h, results); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSlogHandlerZeroTime(t *testing.T) {
+	var got *Entry
+	h := NewSlogHandler(sinkFunc(func(e *Entry) error { got = e; return nil }), nil)
+
+	h.Handle(context.Background(), slog.NewRecord(time.Time{}, slog.LevelInfo, "spam", 0))
+	if !got.Time.IsZero() {
+		t.Errorf("Time = %v, want zero", got.Time)
+	}
+
+	now := time.Now()
+	h.Handle(context.Background(), slog.NewRecord(now, slog.LevelWarn, "spam", 0))
+	if !got.Time.Equal(now) || got.Level != WarnLevel {
+		t.Errorf("Time = %v, Level = %v", got.Time, got.Level)
+	}
+}
@@ -0,0 +1,132 @@
+package logger
+
+import (
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Sink はEntryの書き出し先
+type Sink interface {
+	WriteEntry(e *Entry) error
+}
+
+type writerSink struct {
+	mu  sync.Mutex
+	w   io.Writer
+	enc Encoder
+}
+
+// NewWriterSink はencでエンコードした1行をwに書き出すSinkを作る
+// 1行は1回のWriteで書き込むので、複数のgoroutineから使っても行が混ざることはない
+func NewWriterSink(w io.Writer, enc Encoder) Sink {
+	return &writerSink{w: w, enc: enc}
+}
+
+func (s *writerSink) WriteEntry(e *Entry) error {
+	b, err := s.enc.Encode(e)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(b)
+	return err
+}
+
+// StructuredLogger はメッセージとは別にFieldを持てるロガー
+// log.Printf("%+v", s)のような文字列の埋め込みではなく、
+// key/valueとして出力するのでjqなどで後から機械的に処理しやすい
+type StructuredLogger struct {
+	level  *int32
+	sink   Sink
+	fields []Field
+
+	addCaller  bool
+	callerSkip int
+	stackLevel Level
+}
+
+// NewStructured はsinkに書き出すStructuredLoggerを作る。最低レベルはInfoLevel
+func NewStructured(sink Sink) *StructuredLogger {
+	level := int32(InfoLevel)
+	return &StructuredLogger{level: &level, sink: sink, stackLevel: ErrorLevel}
+}
+
+// NewJSON はwにjsonを書き出すStructuredLoggerを作る
+// flagはlog.SetFlagsと同じで、時刻の表記に使われる
+func NewJSON(w io.Writer, flag int) *StructuredLogger {
+	return NewStructured(NewWriterSink(w, NewJSONEncoder(flag)))
+}
+
+// With はfieldsを常に出力するロガーを返す
+// 返されたロガーと元のロガーは最低レベルを共有する
+func (l *StructuredLogger) With(fields ...Field) *StructuredLogger {
+	c := *l
+	c.fields = make([]Field, 0, len(l.fields)+len(fields))
+	c.fields = append(c.fields, l.fields...)
+	c.fields = append(c.fields, fields...)
+	return &c
+}
+
+// Level は現在の最低レベルを返す
+func (l *StructuredLogger) Level() Level {
+	return Level(atomic.LoadInt32(l.level))
+}
+
+// SetLevel は最低レベルを変える。With()で作ったロガーにも反映される
+func (l *StructuredLogger) SetLevel(lv Level) {
+	atomic.StoreInt32(l.level, int32(lv))
+}
+
+// Enabled はlvの出力が有効かどうかを返す
+func (l *StructuredLogger) Enabled(lv Level) bool {
+	return lv >= l.Level()
+}
+
+// Debug などはすべてl.logを直接呼ぶ。呼び出し元を取るときの深さを揃えるため
+
+func (l *StructuredLogger) Debug(msg string, fields ...Field) { l.log(DebugLevel, msg, fields) }
+func (l *StructuredLogger) Info(msg string, fields ...Field)  { l.log(InfoLevel, msg, fields) }
+func (l *StructuredLogger) Warn(msg string, fields ...Field)  { l.log(WarnLevel, msg, fields) }
+func (l *StructuredLogger) Error(msg string, fields ...Field) { l.log(ErrorLevel, msg, fields) }
+
+// Fatal は出力後にstatus code 1で終了する
+func (l *StructuredLogger) Fatal(msg string, fields ...Field) {
+	l.log(FatalLevel, msg, fields)
+	os.Exit(1)
+}
+
+// Log はlvを指定して出力する
+// 書き込みエラーはlogパッケージと同じく無視する
+func (l *StructuredLogger) Log(lv Level, msg string, fields ...Field) {
+	l.log(lv, msg, fields)
+}
+
+func (l *StructuredLogger) log(lv Level, msg string, fields []Field) {
+	if !l.Enabled(lv) {
+		return
+	}
+	e := &Entry{
+		Time:    time.Now(),
+		Level:   lv,
+		Message: msg,
+		Fields:  l.fields,
+	}
+	if len(fields) > 0 {
+		e.Fields = make([]Field, 0, len(l.fields)+len(fields))
+		e.Fields = append(e.Fields, l.fields...)
+		e.Fields = append(e.Fields, fields...)
+	}
+	// callerAt(stackAt), l.log, l.Infoなど の3つを飛ばす
+	skip := 3 + l.callerSkip
+	if l.addCaller {
+		e.Caller = callerAt(skip)
+	}
+	if lv >= l.stackLevel {
+		e.Stack = stackAt(skip)
+	}
+	l.sink.WriteEntry(e)
+}
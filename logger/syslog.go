@@ -0,0 +1,276 @@
+package logger
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+)
+
+// Facility はsyslogのfacility
+type Facility int
+
+const (
+	FacilityKern   Facility = 0
+	FacilityUser   Facility = 1
+	FacilityDaemon Facility = 3
+	FacilityAuth   Facility = 4
+	FacilityLocal0 Facility = 16
+	FacilityLocal1 Facility = 17
+	FacilityLocal2 Facility = 18
+	FacilityLocal3 Facility = 19
+	FacilityLocal4 Facility = 20
+	FacilityLocal5 Facility = 21
+	FacilityLocal6 Facility = 22
+	FacilityLocal7 Facility = 23
+)
+
+// Levelに対応するsyslogのseverity
+// WARNはwarning(4)、FATALはプロセスが落ちるのでcritical(2)にする
+var severities = [levelCount]int{
+	DebugLevel: 7,
+	InfoLevel:  6,
+	WarnLevel:  4,
+	ErrorLevel: 3,
+	FatalLevel: 2,
+}
+
+// SyslogConfig はSyslogSinkの接続先と出力内容
+type SyslogConfig struct {
+	// Network は"udp", "tcp", "unix", "unixgram"のどれか
+	Network string
+	// Addr は"localhost:514"や"/dev/log"など
+	Addr     string
+	Facility Facility
+	// AppName が空ならos.Args[0]のファイル名
+	AppName string
+	// Hostname が空ならos.Hostname()
+	Hostname string
+	// MsgID が空なら"-"
+	MsgID string
+}
+
+// SyslogSink はEntryをRFC 5424の形式でsyslogに送るSink
+// logパッケージのsyslogはRFC 3164形式でwindowsでは使えないので自前で組み立てる
+// Fieldは [fields@32473 key="value"] のSTRUCTURED-DATAとして送られる
+type SyslogSink struct {
+	conf SyslogConfig
+	// stream はtcpとunix。接続が切れたら再接続する
+	stream bool
+	// octetCount はtcpのみ。RFC 6587のoctet countingでフレーミングする
+	octetCount bool
+	pid        string
+
+	mu     sync.Mutex
+	conn   net.Conn
+	closed bool
+}
+
+// DialSyslog はconfの接続先に接続したSyslogSinkを作る
+func DialSyslog(conf SyslogConfig) (*SyslogSink, error) {
+	if conf.AppName == "" {
+		conf.AppName = filepath.Base(os.Args[0])
+	}
+	if conf.Hostname == "" {
+		conf.Hostname, _ = os.Hostname()
+	}
+	if conf.MsgID == "" {
+		conf.MsgID = "-"
+	}
+	tcp := conf.Network == "tcp" || conf.Network == "tcp4" || conf.Network == "tcp6"
+	s := &SyslogSink{
+		conf:       conf,
+		stream:     tcp || conf.Network == "unix",
+		octetCount: tcp,
+		pid:        strconv.Itoa(os.Getpid()),
+	}
+	conn, err := net.Dial(conf.Network, conf.Addr)
+	if err != nil {
+		return nil, err
+	}
+	s.conn = conn
+	return s, nil
+}
+
+// 32473はドキュメント用に予約されているPEN
+const syslogSDID = "fields@32473"
+
+// Format はeをRFC 5424の1メッセージにする(フレーミングは含まない)
+//
+//	<134>1 2021-01-19T18:57:09.086480+09:00 host app 1234 - [fields@32473 id="123"] spam
+func (s *SyslogSink) Format(e *Entry) []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteByte('<')
+	buf.WriteString(strconv.Itoa(int(s.conf.Facility)*8 + severity(e.Level)))
+	buf.WriteString(">1 ")
+	if e.Time.IsZero() {
+		// 時刻が無い場合はNILVALUE
+		buf.WriteByte('-')
+	} else {
+		buf.WriteString(e.Time.Format(timeLayoutMicro))
+	}
+	buf.WriteByte(' ')
+	buf.WriteString(headerField(s.conf.Hostname, 255))
+	buf.WriteByte(' ')
+	buf.WriteString(headerField(s.conf.AppName, 48))
+	buf.WriteByte(' ')
+	buf.WriteString(s.pid)
+	buf.WriteByte(' ')
+	buf.WriteString(headerField(s.conf.MsgID, 32))
+	buf.WriteByte(' ')
+
+	fields := e.Fields
+	if e.Caller != nil {
+		fields = append([]Field{String("caller", e.Caller.String()), String("func", e.Caller.Function)}, fields...)
+	}
+	if e.Stack != "" {
+		fields = append(fields[:len(fields):len(fields)], String("stack", e.Stack))
+	}
+	if len(fields) == 0 {
+		buf.WriteByte('-')
+	} else {
+		buf.WriteString("[" + syslogSDID)
+		for _, f := range fields {
+			buf.WriteByte(' ')
+			buf.WriteString(sdName(f.Key))
+			buf.WriteString(`="`)
+			appendSDValue(buf, logfmtString(f.Value))
+			buf.WriteByte('"')
+		}
+		buf.WriteByte(']')
+	}
+
+	if e.Message != "" {
+		buf.WriteByte(' ')
+		// UTF-8のMSGはBOMをつけることになっている
+		if !isASCII(e.Message) {
+			buf.WriteString("\xef\xbb\xbf")
+		}
+		buf.WriteString(e.Message)
+	}
+	return buf.Bytes()
+}
+
+func severity(lv Level) int {
+	if lv < DebugLevel {
+		return severities[DebugLevel]
+	}
+	if lv > FatalLevel {
+		return severities[FatalLevel]
+	}
+	return severities[lv]
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// ヘッダの各項目は空白を含まないASCIIで長さの上限がある。空なら"-"
+func headerField(s string, max int) string {
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s) && len(b) < max; i++ {
+		if c := s[i]; c > ' ' && c < 0x7f {
+			b = append(b, c)
+		}
+	}
+	if len(b) == 0 {
+		return "-"
+	}
+	return string(b)
+}
+
+// SD-NAMEには = ] " とスペースが使えず、32文字まで
+func sdName(s string) string {
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s) && len(b) < 32; i++ {
+		c := s[i]
+		if c <= ' ' || c >= 0x7f || c == '=' || c == ']' || c == '"' {
+			c = '_'
+		}
+		b = append(b, c)
+	}
+	if len(b) == 0 {
+		return "_"
+	}
+	return string(b)
+}
+
+// PARAM-VALUEでは " \ ] をエスケープする
+func appendSDValue(buf *bytes.Buffer, s string) {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"', '\\', ']':
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		default:
+			buf.WriteByte(c)
+		}
+	}
+}
+
+// WriteEntry はeを送る
+// tcpではRFC 6587のoctet countingでフレーミングする
+// ローカルのsyslogdのunixソケットはフレーミングを期待しないので、log/syslogと同じく改行で区切る
+// (/dev/logは普通unixgramなので、そちらは1メッセージ1データグラムで区切りは不要)
+// tcpとunixで接続が切れていた場合は一度だけ再接続して送り直す
+// Close後はos.ErrClosedを返す
+func (s *SyslogSink) WriteEntry(e *Entry) error {
+	msg := s.Format(e)
+	switch {
+	case s.octetCount:
+		msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	case s.stream:
+		// 改行で区切るので、スタックトレースなどの複数行の値はエスケープして1行にする
+		msg = append(escapeNewlines(msg), '\n')
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return os.ErrClosed
+	}
+
+	if s.conn != nil {
+		if _, err := s.conn.Write(msg); err == nil || !s.stream {
+			return err
+		}
+		s.conn.Close()
+		s.conn = nil
+	}
+
+	conn, err := net.Dial(s.conf.Network, s.conf.Addr)
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	_, err = s.conn.Write(msg)
+	return err
+}
+
+// \r\nと\nを文字としての\nに置き換える
+// SDのPARAM-VALUEでは " \ ] 以外の前の\はそのまま\として扱われる
+func escapeNewlines(msg []byte) []byte {
+	msg = bytes.ReplaceAll(msg, []byte("\r\n"), []byte(`\n`))
+	return bytes.ReplaceAll(msg, []byte("\n"), []byte(`\n`))
+}
+
+// Close は接続を閉じる。以降のWriteEntryはos.ErrClosedを返す
+func (s *SyslogSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
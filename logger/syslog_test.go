@@ -0,0 +1,169 @@
+package logger
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func testSyslogConfig(network, addr string) SyslogConfig {
+	return SyslogConfig{
+		Network:  network,
+		Addr:     addr,
+		Facility: FacilityLocal0,
+		AppName:  "app",
+		Hostname: "host",
+	}
+}
+
+// syslogdの代わりにudpで受け取る
+func TestSyslogSinkUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	s, err := DialSyslog(testSyslogConfig("udp", pc.LocalAddr().String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	tests := []struct {
+		log  func(l *StructuredLogger)
+		want string
+	}{
+		{
+			func(l *StructuredLogger) { l.Info("spam", Int("id", 123), String("q", `a"b]`)) },
+			`^<134>1 \S+ host app ` + strconv.Itoa(os.Getpid()) + ` - \[fields@32473 id="123" q="a\\"b\\]"\] spam$`,
+		},
+		{
+			func(l *StructuredLogger) { l.Warn("スパム") },
+			`^<132>1 \S+ host app \d+ - - \x{feff}スパム$`,
+		},
+	}
+	l := NewStructured(s).WithStacktrace(FatalLevel + 1)
+	buf := make([]byte, 64*1024)
+	for _, tt := range tests {
+		tt.log(l)
+		pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(buf[:n]); !regexp.MustCompile(tt.want).MatchString(got) {
+			t.Errorf("got %q, want match %s", got, tt.want)
+		}
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.WriteEntry(&Entry{Message: "after close"}); err != os.ErrClosed {
+		t.Errorf("WriteEntry after Close = %v, want os.ErrClosed", err)
+	}
+}
+
+// tcpではoctet countingでフレーミングする
+func TestSyslogSinkTCPFraming(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	s, err := DialSyslog(testSyslogConfig("tcp", ln.Addr().String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	l := NewStructured(s)
+	l.Info("one")
+	l.Info("two words")
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(conn)
+	for _, msg := range []string{"one", "two words"} {
+		size, err := r.ReadString(' ')
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := strconv.Atoi(size[:len(size)-1])
+		if err != nil {
+			t.Fatalf("bad frame length %q", size)
+		}
+		b := make([]byte, n)
+		if _, err := io.ReadFull(r, b); err != nil {
+			t.Fatal(err)
+		}
+		if want := " - - " + msg; string(b[len(b)-len(want):]) != want {
+			t.Errorf("got %q, want suffix %q", b, want)
+		}
+	}
+}
+
+// unixソケットはフレーミングせず改行で区切る
+func TestSyslogSinkUnixStream(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets are not available")
+	}
+	path := filepath.Join(t.TempDir(), "log.sock")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	s, err := DialSyslog(testSyslogConfig("unix", path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	l := NewStructured(s)
+	l.Info("spam")
+	// Errorにはスタックトレースがつくが、改行はエスケープされて1件が1行になる
+	l.Error("boom\nsecond line")
+	l.Info("eggs")
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(conn)
+	var lines []string
+	for i := 0; i < 3; i++ {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, line)
+	}
+	if !regexp.MustCompile(`^<134>1 \S+ host app \d+ - - spam\n$`).MatchString(lines[0]) {
+		t.Errorf("got %q", lines[0])
+	}
+	if !regexp.MustCompile(`^<131>1 .*\[fields@32473 stack="[^"]*TestSyslogSinkUnixStream[^"]*\\n\t[^"]*"\] boom\\nsecond line\n$`).MatchString(lines[1]) {
+		t.Errorf("got %q", lines[1])
+	}
+	if !regexp.MustCompile(`^<134>1 \S+ host app \d+ - - eggs\n$`).MatchString(lines[2]) {
+		t.Errorf("got %q", lines[2])
+	}
+}
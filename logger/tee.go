@@ -0,0 +1,230 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TeeBranch はTeeの出力先1つ分
+type TeeBranch struct {
+	// Name はエラー報告で使う名前
+	Name string
+	// Sink は出力先。エンコーダーはNewWriterSinkなどで出力先ごとに選ぶ
+	Sink Sink
+	// Level はこの出力先の最低レベル
+	Level Level
+	// QueueSize はこの出力先のキューの長さ。0なら1024
+	// 出力が追いつかずキューが一杯になった場合、その出力先の分だけ捨ててDroppedで数える
+	QueueSize int
+}
+
+const (
+	defaultTeeQueueSize    = 1024
+	defaultTeeFlushTimeout = 5 * time.Second
+)
+
+// Tee は1つのEntryを複数のSinkに出力するSink
+// log.goのhandlers()で触れていた「エラーレベルによってハンドラを変える」を
+// 出力先ごとの最低レベルとして設定できるようにしたもの
+//
+//	tee := logger.NewTee(
+//		logger.TeeBranch{Name: "stdout", Sink: logger.NewWriterSink(os.Stdout, logger.NewConsoleEncoder(os.Stdout, log.LstdFlags, "")), Level: logger.InfoLevel},
+//		logger.TeeBranch{Name: "file", Sink: logger.NewWriterSink(rotating, logger.NewJSONEncoder(log.LstdFlags)), Level: logger.DebugLevel},
+//		logger.TeeBranch{Name: "syslog", Sink: syslogSink, Level: logger.ErrorLevel},
+//	)
+//	defer tee.Close()
+//	l := logger.NewStructured(tee)
+//	l.SetLevel(logger.DebugLevel) // ロガー側では絞らず出力先ごとに絞る
+//
+// 出力先ごとにAsyncSinkのキューとgoroutineを持つので、
+// ある出力先が遅くても止まっても、エラーを返してもpanicしても他の出力先には影響しない
+// エラーはOnErrorで報告する。FlushとCloseでも止まっている出力先はFlushTimeoutまでしか待たない
+//
+// キューに残っている分を書き出すため、終了時には必ずCloseする
+type Tee struct {
+	branches []teeBranch
+
+	// OnError は出力先がエラーを返したときに呼ばれる。nilなら標準エラー出力に書き出す
+	// キューが一杯で捨てた場合も、その出力先の次の書き出しかFlushのときに
+	// errors.Is(err, ErrDropped)となるエラーでまとめて報告する
+	// 出力先ごとのgoroutineから同時に呼ばれることがある
+	OnError func(name string, err error)
+	// FlushTimeout はFlush, Close, Fatalのときに出力先ごとに待つ時間。0なら5秒
+	// 止まっている出力先があっても終了できるようにするため
+	FlushTimeout time.Duration
+
+	mu     sync.Mutex
+	errors map[string]uint64
+}
+
+type teeBranch struct {
+	TeeBranch
+	async *AsyncSink
+	w     *teeWriter
+}
+
+// NewTee はbranchesに出力するTeeを作る
+func NewTee(branches ...TeeBranch) *Tee {
+	t := &Tee{errors: map[string]uint64{}}
+	for _, b := range branches {
+		size := b.QueueSize
+		if size <= 0 {
+			size = defaultTeeQueueSize
+		}
+		w := &teeWriter{t: t, b: b}
+		w.async = NewAsyncSink(w, size, OverflowDrop)
+		t.branches = append(t.branches, teeBranch{b, w.async, w})
+	}
+	return t
+}
+
+// WriteEntry は最低レベルを満たすすべての出力先のキューにeを積んですぐに戻る
+// 出力先のエラーはOnErrorで報告されるので、Close後のos.ErrClosed以外は返さない
+// FatalLevelの場合は直後に終了するので、FlushTimeoutまで書き出されるのを待つ
+func (t *Tee) WriteEntry(e *Entry) error {
+	for _, b := range t.branches {
+		if e.Level < b.Level {
+			continue
+		}
+		if err := b.async.WriteEntry(e); err != nil {
+			return err
+		}
+	}
+	if e.Level >= FatalLevel {
+		t.Flush()
+	}
+	return nil
+}
+
+// teeWriter は出力先のgoroutineで呼ばれ、エラーとpanic、捨てた件数をOnErrorに報告する
+type teeWriter struct {
+	t     *Tee
+	b     TeeBranch
+	async *AsyncSink
+	// reported は報告済みの捨てた件数
+	reported uint64
+}
+
+func (w *teeWriter) WriteEntry(e *Entry) error {
+	w.reportDropped()
+	if err := w.write(e); err != nil {
+		w.t.report(w.b.Name, err)
+	}
+	return nil
+}
+
+func (w *teeWriter) reportDropped() {
+	n := w.async.Dropped()
+	for {
+		prev := atomic.LoadUint64(&w.reported)
+		if n <= prev {
+			return
+		}
+		if atomic.CompareAndSwapUint64(&w.reported, prev, n) {
+			w.t.notify(w.b.Name, fmt.Errorf("%w %d entries", ErrDropped, n-prev))
+			return
+		}
+	}
+}
+
+// 出力先のpanicも他に影響させない
+func (w *teeWriter) write(e *Entry) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return w.b.Sink.WriteEntry(e)
+}
+
+// Flush はそれまでにWriteEntryした分がすべての出力先に書き出されるまで待つ
+// FlushTimeoutまでに終わらなかった出力先があれば、OnErrorで報告してその名前をエラーで返す
+func (t *Tee) Flush() error {
+	return t.wait("flush", func(b teeBranch) { b.async.Flush() })
+}
+
+// Close はキューに残っている分を書き出してから止める。出力先のSinkは閉じない
+// 待つのはFlushと同じくFlushTimeoutまで
+func (t *Tee) Close() error {
+	return t.wait("close", func(b teeBranch) { b.async.Close() })
+}
+
+// 出力先ごとにfを並行に呼び、FlushTimeoutまで待つ
+func (t *Tee) wait(op string, f func(b teeBranch)) error {
+	timeout := t.FlushTimeout
+	if timeout <= 0 {
+		timeout = defaultTeeFlushTimeout
+	}
+
+	done := make([]chan struct{}, len(t.branches))
+	for i, b := range t.branches {
+		done[i] = make(chan struct{})
+		go func(b teeBranch, done chan struct{}) {
+			f(b)
+			close(done)
+		}(b, done[i])
+	}
+
+	deadline := time.Now().Add(timeout)
+	var hung []string
+	for i, b := range t.branches {
+		select {
+		case <-done[i]:
+			b.w.reportDropped()
+			continue
+		default:
+		}
+		timer := time.NewTimer(time.Until(deadline))
+		select {
+		case <-done[i]:
+			timer.Stop()
+			b.w.reportDropped()
+		case <-timer.C:
+			hung = append(hung, b.Name)
+			t.report(b.Name, fmt.Errorf("%s timed out after %v", op, timeout))
+		}
+	}
+	if len(hung) > 0 {
+		return fmt.Errorf("logger: tee: %s timed out: %s", op, strings.Join(hung, ", "))
+	}
+	return nil
+}
+
+// Dropped は出力先ごとの、キューが一杯で捨てた件数を返す
+func (t *Tee) Dropped() map[string]uint64 {
+	m := make(map[string]uint64, len(t.branches))
+	for _, b := range t.branches {
+		m[b.Name] = b.async.Dropped()
+	}
+	return m
+}
+
+func (t *Tee) report(name string, err error) {
+	t.mu.Lock()
+	t.errors[name]++
+	t.mu.Unlock()
+	t.notify(name, err)
+}
+
+func (t *Tee) notify(name string, err error) {
+	if t.OnError != nil {
+		t.OnError(name, err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "logger: tee %s: %v\n", name, err)
+}
+
+// Errors は出力先ごとのエラー回数を返す
+func (t *Tee) Errors() map[string]uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	m := make(map[string]uint64, len(t.errors))
+	for k, v := range t.errors {
+		m[k] = v
+	}
+	return m
+}
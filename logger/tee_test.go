@@ -0,0 +1,189 @@
+package logger
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// sinkFunc はテスト用に関数をSinkにする
+type sinkFunc func(e *Entry) error
+
+func (f sinkFunc) WriteEntry(e *Entry) error { return f(e) }
+
+// recordSink は受け取ったメッセージを記録する
+type recordSink struct {
+	mu   sync.Mutex
+	msgs []string
+}
+
+func (r *recordSink) WriteEntry(e *Entry) error {
+	r.mu.Lock()
+	r.msgs = append(r.msgs, e.Message)
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *recordSink) messages() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.msgs...)
+}
+
+func TestTeeLevels(t *testing.T) {
+	all, errs := &recordSink{}, &recordSink{}
+	tee := NewTee(
+		TeeBranch{Name: "all", Sink: all, Level: DebugLevel},
+		TeeBranch{Name: "errors", Sink: errs, Level: ErrorLevel},
+	)
+	l := NewStructured(tee)
+	l.SetLevel(DebugLevel)
+	l.Debug("debug")
+	l.Error("error")
+	tee.Close()
+
+	if got := all.messages(); len(got) != 2 || got[0] != "debug" || got[1] != "error" {
+		t.Errorf("all = %v", got)
+	}
+	if got := errs.messages(); len(got) != 1 || got[0] != "error" {
+		t.Errorf("errors = %v", got)
+	}
+}
+
+func TestTeeSlowBranchDoesNotBlock(t *testing.T) {
+	release := make(chan struct{})
+	fast := &recordSink{}
+	tee := NewTee(
+		TeeBranch{Name: "hung", Sink: sinkFunc(func(*Entry) error { <-release; return nil }), QueueSize: 1},
+		TeeBranch{Name: "fast", Sink: fast},
+	)
+
+	start := time.Now()
+	l := NewStructured(tee)
+	for i := 0; i < 10; i++ {
+		l.Info("spam")
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("WriteEntry blocked for %v", d)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(fast.messages()) < 10 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := len(fast.messages()); got != 10 {
+		t.Errorf("fast branch got %d entries, want 10", got)
+	}
+	// 1件は止まっている出力先で処理中、1件はキューの中、残りは捨てられる
+	if d := tee.Dropped()["hung"]; d < 8 {
+		t.Errorf("Dropped()[hung] = %d, want >= 8", d)
+	}
+
+	close(release)
+	tee.Close()
+}
+
+func TestTeeErrorIsolation(t *testing.T) {
+	ok := &recordSink{}
+	var mu sync.Mutex
+	reported := map[string]int{}
+	tee := NewTee(
+		TeeBranch{Name: "failing", Sink: sinkFunc(func(*Entry) error { return errors.New("disk full") })},
+		TeeBranch{Name: "panicking", Sink: sinkFunc(func(*Entry) error { panic("boom") })},
+		TeeBranch{Name: "ok", Sink: ok},
+	)
+	tee.OnError = func(name string, err error) {
+		mu.Lock()
+		reported[name]++
+		mu.Unlock()
+	}
+
+	l := NewStructured(tee)
+	l.Info("a")
+	l.Info("b")
+	tee.Close()
+
+	if got := ok.messages(); len(got) != 2 {
+		t.Errorf("ok = %v", got)
+	}
+	errs := tee.Errors()
+	if errs["failing"] != 2 || errs["panicking"] != 2 || errs["ok"] != 0 {
+		t.Errorf("Errors() = %v", errs)
+	}
+	if reported["failing"] != 2 || reported["panicking"] != 2 {
+		t.Errorf("OnError = %v", reported)
+	}
+	if err := tee.WriteEntry(&Entry{Message: "c"}); err == nil {
+		t.Error("WriteEntry after Close should fail")
+	}
+}
+
+func TestTeeCloseWithHungBranch(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	fast := &recordSink{}
+	tee := NewTee(
+		TeeBranch{Name: "hung", Sink: sinkFunc(func(*Entry) error { <-release; return nil }), QueueSize: 1},
+		TeeBranch{Name: "fast", Sink: fast},
+	)
+	tee.FlushTimeout = 50 * time.Millisecond
+	tee.OnError = func(string, error) {}
+
+	l := NewStructured(tee)
+	for i := 0; i < 5; i++ {
+		l.Info("spam")
+	}
+
+	start := time.Now()
+	err := tee.Close()
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Close took %v", d)
+	}
+	if err == nil || !strings.Contains(err.Error(), "hung") || strings.Contains(err.Error(), "fast") {
+		t.Errorf("Close() = %v", err)
+	}
+	if got := len(fast.messages()); got != 5 {
+		t.Errorf("fast branch got %d entries, want 5", got)
+	}
+	if tee.Errors()["hung"] != 1 {
+		t.Errorf("Errors() = %v", tee.Errors())
+	}
+}
+
+func TestTeeReportsDropped(t *testing.T) {
+	release := make(chan struct{})
+	var mu sync.Mutex
+	dropped := map[string]int{}
+	tee := NewTee(TeeBranch{Name: "slow", Sink: sinkFunc(func(*Entry) error { <-release; return nil }), QueueSize: 1})
+	tee.OnError = func(name string, err error) {
+		if errors.Is(err, ErrDropped) {
+			mu.Lock()
+			dropped[name]++
+			mu.Unlock()
+		}
+	}
+
+	l := NewStructured(tee)
+	for i := 0; i < 10; i++ {
+		l.Info("spam")
+	}
+	close(release)
+	if err := tee.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	tee.Close()
+
+	if tee.Dropped()["slow"] == 0 {
+		t.Fatal("nothing was dropped")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if dropped["slow"] == 0 {
+		t.Error("drops were not reported to OnError")
+	}
+	if tee.Errors()["slow"] != 0 {
+		t.Errorf("drops should not be counted as errors: %v", tee.Errors())
+	}
+}